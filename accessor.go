@@ -0,0 +1,62 @@
+package goxsd
+
+// Getters of the pointer fields of a struct, generated with
+// Options.Accessors. They can be called on a nil struct, so that a chain of
+// getters reaches through optional elements without checking each one.
+var accessors = `{{ define "Accessors" }}{{ range $a := accessors . }}
+{{ if $a.Struct }}{{ printf "// %s returns v.%s, or nil if v is nil.\n" $a.Name $a.Field }}{{ printf "func (v *%s) %s() *%s {\n" $a.Recv $a.Name $a.Type }}if v == nil {
+return nil
+}
+{{ printf "return v.%s\n" $a.Field }}}
+{{ else }}{{ printf "// %s returns *v.%s, or the zero value if v or v.%s is nil.\n" $a.Name $a.Field $a.Field }}{{ printf "func (v *%s) %s() %s {\n" $a.Recv $a.Name $a.Type }}{{ printf "if v == nil || v.%s == nil {\n" $a.Field }}{{ printf "var zero %s\n" $a.Type }}return zero
+}
+{{ printf "return *v.%s\n" $a.Field }}}
+{{ end }}{{ end }}{{ end }}`
+
+// accessor is the getter of a pointer field.
+type accessor struct {
+	Recv  string // struct the getter is a method of
+	Name  string
+	Field string
+	Type  string // type the field points to
+	// the field points to a struct, which the getter returns as a pointer,
+	// rather than a value
+	Struct bool
+}
+
+// accessorsOf returns the getters of the pointer fields of the struct
+// generated for e. Getters named like a field of the struct are left out.
+func (g generator) accessorsOf(e *xmlTree, typeName func(string) string) []accessor {
+	recv := typeName(structName(e))
+	fields := make(map[string]struct{})
+	for _, c := range e.Children {
+		fields[g.childField(c)] = struct{}{}
+	}
+	for i := range e.Attribs {
+		fields[g.attrField(e, i).Field] = struct{}{}
+	}
+
+	var as []accessor
+	add := func(a accessor) {
+		a.Recv, a.Name = recv, "Get"+a.Field
+		if _, ok := fields[a.Name]; !ok {
+			as = append(as, a)
+		}
+	}
+	for i, a := range e.Attribs {
+		if g.attrPointer(a) {
+			add(accessor{Field: g.attrField(e, i).Field, Type: g.useType(g.goType(a.Type))})
+		}
+	}
+	for _, c := range e.Children {
+		if c.Choice || c.List || !g.childPointer(c) {
+			continue
+		}
+		add(accessor{
+			Field:  g.childField(c),
+			Type:   g.useType(g.fieldType(c)),
+			Struct: !primitiveType(c) && !enumType(c) && !patternType(c),
+		})
+	}
+	return as
+}
@@ -0,0 +1,179 @@
+package goxsd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// check reports every XSD construct in the schemas that goxsd does not
+// represent, one per line, with its location and the named definition it
+// appears in. The constructs goxsd supports are exactly those that the xsd*
+// structs decode, so anything the decoding would silently drop is reported.
+func check(schemas []xsdSchema) ([]string, error) {
+	var reports []string
+	for _, s := range schemas {
+		r, err := checkSchema(s.loc, s.data)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r...)
+	}
+	return reports, nil
+}
+
+// checkSchema walks the XSD elements of a schema document alongside the
+// structs they decode into.
+func checkSchema(loc string, data []byte) ([]string, error) {
+	type frame struct {
+		node    decodeNode
+		context string // named definition the element belongs to
+		skip    bool   // the element and its content are not checked
+		wsdl    bool   // the element wraps the schemas of a WSDL document
+	}
+
+	var reports []string
+	root := decodeNode{{path: []string{"schema"}, typ: reflect.TypeOf(xsdSchema{})}}
+	stack := []frame{{node: root}}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			return reports, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", loc, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			top := stack[len(stack)-1]
+			f := frame{context: top.context, skip: top.skip}
+			if name := attrValue(t, "name"); name != "" {
+				f.context = fmt.Sprintf("%s '%s'", t.Name.Local, name)
+			}
+
+			// The definitions of a WSDL document, and their types, only
+			// wrap its schemas
+			if len(stack) == 1 && wsdlRoot(t.Name) || top.wsdl && wsdlTypes(t.Name) {
+				stack = append(stack, frame{node: top.node, wsdl: true})
+				continue
+			}
+
+			// Documentation is free form, and foreign elements are no
+			// concern of the schema
+			foreign := t.Name.Space != "" && t.Name.Space != xsdNamespace
+			if t.Name.Local == "annotation" || foreign {
+				f.skip = true
+			}
+			if !f.skip {
+				if f.node = top.node.child(t.Name.Local); f.node == nil {
+					reports = append(reports, fmt.Sprintf("%s:%d: unsupported %s%s",
+						loc, lineAt(data, offset), t.Name.Local, inContext(top.context)))
+					f.skip = true
+				} else if f.node.ignored() {
+					f.skip = true
+				}
+			}
+			stack = append(stack, f)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+func attrValue(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// wsdlTypes reports whether an element of a WSDL document is its types
+// element.
+func wsdlTypes(name xml.Name) bool {
+	return name.Local == "types" && (name.Space == wsdl11Namespace || name.Space == wsdl20Namespace)
+}
+
+func inContext(context string) string {
+	if context == "" {
+		return ""
+	}
+	return " in " + context
+}
+
+// lineAt returns the line number at a byte offset of data.
+func lineAt(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// decodeNode describes what an XML element decodes into: the struct fields
+// whose tag paths continue below it.
+type decodeNode []decodePath
+
+type decodePath struct {
+	path []string // remaining element names of the tag path
+	typ  reflect.Type
+}
+
+// child returns the node of a child element, or nil if no field decodes it.
+func (n decodeNode) child(name string) decodeNode {
+	var c decodeNode
+	for _, p := range n {
+		if len(p.path) > 0 {
+			if p.path[0] == name {
+				c = append(c, decodePath{path: p.path[1:], typ: p.typ})
+			}
+			continue
+		}
+		c = append(c, elementFields(p.typ, name)...)
+	}
+	return c
+}
+
+// ignored reports whether the element decodes into an xsdIgnored, whose
+// content is not checked.
+func (n decodeNode) ignored() bool {
+	for _, p := range n {
+		t := p.typ
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if len(p.path) == 0 && t == reflect.TypeOf(xsdIgnored{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// elementFields returns the paths below the fields of struct type t whose
+// tag paths start with the element name.
+func elementFields(t reflect.Type, name string) decodeNode {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var n decodeNode
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("xml"), ",")
+		if tag[0] == "" || tag[0] == "-" || len(tag) > 1 {
+			continue
+		}
+		path := strings.Split(tag[0], ">")
+		if path[0] == name {
+			n = append(n, decodePath{path: path[1:], typ: t.Field(i).Type})
+		}
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package goxsd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interface and branch types of the choice of a struct, generated with
+// Options.Choice interface, along with the methods decoding and encoding
+// the struct with the element of its choice, or with the elements of a
+// choice that repeats. The fields of the struct are embedded under an
+// exported type, through which encoding/xml can set a promoted XMLName.
+var choice = `{{ define "Choice" }}{{ with $c := choice . }}
+{{ printf "// %s is one of the elements of the choice of a %s.\n" .Interface .Type }}{{ printf "type %s interface {\n%s()\n}\n" .Interface .Method }}{{ range $b := .Branches }}
+{{ printf "// %s is the %s element of the choice of a %s.\n" $b.Type $b.Element $c.Type }}{{ printf "type %s %s\n\n" $b.Type $b.Base }}{{ printf "func (*%s) %s() {}\n" $b.Type $c.Method }}{{ end }}{{ if .List }}{{ template "ChoiceList" . }}{{ else }}
+{{ printf "// UnmarshalXML decodes a %s, setting its %s to the element of its choice.\n" .Type .Field }}{{ printf "func (v *%s) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {\n" .Type }}{{ printf "type Plain %s\n" .Type }}var p struct {
+Plain
+{{ range $b := .Branches }}{{ printf "%s *%s %s\n" $b.Field $b.Type $b.Tag }}{{ end }}}
+if err := d.DecodeElement(&p, &start); err != nil {
+return err
+}
+{{ printf "*v = %s(p.Plain)\n" .Type }}switch {
+{{ range $b := .Branches }}{{ printf "case p.%s != nil:\nv.%s = p.%s\n" $b.Field $c.Field $b.Field }}{{ end }}}
+return nil
+}
+
+{{ printf "// MarshalXML encodes a %s along with the element of its choice.\n" .Type }}{{ printf "func (v %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n" .Type }}{{ printf "type Plain %s\n" .Type }}p := struct {
+Plain
+{{ range $b := .Branches }}{{ printf "%s *%s %s\n" $b.Field $b.Type $b.Tag }}{{ end }}}{Plain: Plain(v)}
+{{ printf "switch c := v.%s.(type) {\n" .Field }}{{ range $b := .Branches }}{{ printf "case *%s:\np.%s = c\n" $b.Type $b.Field }}{{ end }}}
+return e.EncodeElement(p, start)
+}
+{{ end }}{{ end }}{{ end }}`
+
+// Element type of a choice that repeats, decoding and encoding an element of
+// the choice by its name, and the methods decoding and encoding the struct
+// with the elements of its choice, in document order
+var choiceList = `{{ define "ChoiceList" }}{{ $c := . }}
+{{ printf "// %s holds an element of the choice of a %s.\n" .Element .Type }}{{ printf "type %s struct {\nchoice %s\n}\n" .Element .Interface }}
+// UnmarshalXML decodes the element of the choice named by start, skipping
+// any other element.
+{{ printf "func (v *%s) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {\n" .Element }}switch start.Name.Local {
+{{ range $b := .Branches }}{{ printf "case %q:\nc := new(%s)\nv.choice = c\nreturn d.DecodeElement(c, &start)\n" $b.Element $b.Type }}{{ end }}}
+return d.Skip()
+}
+
+// MarshalXML encodes the element of the choice under its name.
+{{ printf "func (v %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n" .Element }}switch c := v.choice.(type) {
+{{ range $b := .Branches }}{{ printf "case *%s:\nreturn e.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: %q}})\n" $b.Type $b.Element }}{{ end }}}
+return nil
+}
+
+{{ printf "// UnmarshalXML decodes a %s, appending the elements of its choice to its\n" .Type }}{{ printf "// %s.\n" .Field }}{{ printf "func (v *%s) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {\n" .Type }}{{ printf "type Plain %s\n" .Type }}var p struct {
+Plain
+{{ printf "Elements []%s %s\n" .Element (choiceElementsTag) }}}
+if err := d.DecodeElement(&p, &start); err != nil {
+return err
+}
+{{ printf "*v = %s(p.Plain)\n" .Type }}for _, c := range p.Elements {
+if c.choice != nil {
+{{ printf "v.%s = append(v.%s, c.choice)\n" .Field .Field }}}
+}
+return nil
+}
+
+{{ printf "// MarshalXML encodes a %s along with the elements of its choice.\n" .Type }}{{ printf "func (v %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n" .Type }}{{ printf "type Plain %s\n" .Type }}p := struct {
+Plain
+{{ printf "Elements []%s %s\n" .Element (choiceElementsTag) }}}{Plain: Plain(v)}
+{{ printf "for _, c := range v.%s {\n" .Field }}{{ printf "p.Elements = append(p.Elements, %s{c})\n" .Element }}}
+return e.EncodeElement(p, start)
+}
+{{ end }}`
+
+// choiceData is the data of the choice of a struct.
+type choiceData struct {
+	Type      string
+	Field     string // field holding the element of the choice
+	Interface string
+	Method    string // unexported method of the interface
+	Branches  []choiceBranch
+	// the choice repeats, and Field is a slice of its elements, decoded
+	// and encoded as the Element type
+	List    bool
+	Element string
+}
+
+// choiceBranch is an element of a choice.
+type choiceBranch struct {
+	Element string
+	Type    string // named type of the element, implementing the interface
+	Base    string // Go type the named type is defined as
+	Field   string // field of the element in the struct decoded into
+	Tag     string
+}
+
+// choiceField is the name of the field holding the element of a choice.
+const choiceField = "Choice"
+
+// choice returns the data of the choice of the struct generated for e, or
+// nil if it has no choice generated as an interface.
+func (g generator) choice(e *xmlTree, typeName func(string) string) *choiceData {
+	if !choiceType(e) {
+		return nil
+	}
+	name := structName(e)
+	c := &choiceData{
+		Type:      typeName(name),
+		Field:     choiceField,
+		Interface: typeName(name + "Choice"),
+	}
+	c.Method = "is" + strings.Title(c.Interface)
+	// The methods of the struct decode and encode the choice
+	g.used.add("encoding/xml")
+	if e.ChoiceList {
+		c.List = true
+		c.Element = typeName(name + "ChoiceElement")
+	}
+	for _, b := range e.Children {
+		if !b.Choice {
+			continue
+		}
+		// Structs, list types and dates are embedded rather than
+		// redefined, which keeps their methods, such as those decoding
+		// their own choices or splitting the values of the list
+		base := g.useType(g.fieldType(b))
+		if simpleList(b) {
+			base = g.listType(b.Type)
+		}
+		switch {
+		case b.List:
+			base = "[]" + base
+		case simpleList(b) || b.Type == dateType || !primitiveType(b) && !enumType(b) && !patternType(b):
+			base = "struct{ " + base + " }"
+		}
+		c.Branches = append(c.Branches, choiceBranch{
+			Element: b.Name,
+			Type:    typeName(name + strings.Title(b.Name)),
+			Base:    base,
+			Field:   g.childField(b),
+			Tag:     g.childTag(b),
+		})
+	}
+	return c
+}
+
+// choiceType reports whether the struct generated for e has a choice
+// generated as an interface.
+func choiceType(e *xmlTree) bool {
+	for _, c := range e.Children {
+		if c.Choice {
+			return true
+		}
+	}
+	return false
+}
+
+// choiceDoc documents the field holding the element of a choice.
+func choiceDoc(c *choiceData) string {
+	types := make([]string, len(c.Branches))
+	for i, b := range c.Branches {
+		types[i] = "*" + b.Type
+	}
+	if c.List {
+		return fmt.Sprintf("// %s holds the elements of the choice, in document order, each one of %s.", c.Field, strings.Join(types, ", "))
+	}
+	return fmt.Sprintf("// %s is the element of the choice, one of %s.", c.Field, strings.Join(types, ", "))
+}
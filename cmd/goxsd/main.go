@@ -0,0 +1,399 @@
+// Command goxsd generates XML decoding/encoding Go structs from an XSD
+// schema. It is a thin wrapper around package goxsd.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottjbarr/goxsd"
+)
+
+var (
+	output, indent    string
+	archive           string
+	initialisms       string
+	checkOnly         bool
+	titleCaseAcronyms bool
+	split             bool
+	verbose           bool
+	opts              goxsd.Options
+
+	// errorLog reports what makes goxsd fail, and verboseLog, enabled by
+	// -v, how the schema is built. Both write to stderr, keeping stdout for
+	// the generated code.
+	errorLog   = log.New(os.Stderr, "", 0)
+	verboseLog = log.New(ioutil.Discard, "", 0)
+
+	usage = `Usage: goxsd [options] <xsd_file|dir>
+
+The XSD is read from stdin if <xsd_file> is -, with relative imports resolved
+against the working directory. Given a directory, code is generated for all
+of its XSD files together. A WSDL document may be given instead of an XSD,
+generating code for the schemas it embeds.
+
+Options:
+  -o <file>     Destination file, or directory with -split [default: stdout]
+  -p <package>  Package name, also -package [default: goxsd]
+  -e            Generate exported structs [default: false]
+  -unexported   Generate unexported structs, even for the XSD types and
+                elements whose names start with an upper case letter
+                [default: false]
+  -x <prefix>   Struct name prefix, also -prefix [default: ""]
+  -no-network   Fail on http(s) schema locations instead of fetching them
+  -json         Generate json struct tags next to the xml tags [default: false]
+  -fieldtag <key>
+                Struct tag key repeating the value of every xml tag, such as
+                form for xml:"name" form:"name". May be repeated
+  -comments     Comment each type with the XSD type it is generated from
+                [default: false]
+  -chardata-name <name>
+                Name of character data fields, empty to name them after
+                their element [default: Value]
+  -check        Only report the schema constructs that goxsd does not
+                support, failing if there are any
+  -indent <tab|n>
+                Indent with tabs, or with n spaces [default: tab]
+  -validate     Generate Validate methods checking the pattern and length
+                facets of string values [default: false]
+  -pattern-types
+                Generate a named string type with a Validate method for
+                every simple type restricted by a pattern [default: false]
+  -flatten-wrappers
+                Replace the fields of elements that only wrap a list of
+                another element by the list [default: false]
+  -choice <flatten|interface>
+                Generate the elements of the choice of a complex type as
+                optional fields, or as the implementations of an interface
+                field, decoded by the name of the element [default: flatten]
+  -use-pointers <all|optional|none>
+                Fields that are pointers: those of all elements and
+                attributes, of optional elements, or none [default: optional]
+  -initialisms <list>
+                Comma separated initialisms to upper case in Go names, in
+                addition to those of golint, such as ID and URL
+  -title-case-acronyms
+                Upper case initialisms in Go names; with
+                -title-case-acronyms=false, names keep the case of the
+                schema but for their first letter, and -initialisms cannot
+                be given [default: true]
+  -strict       Fail on type names that match no definition, instead of
+                warning about them and generating strings
+  -timestamp    Add the time of generation to the header [default: false]
+  -constructors Generate New functions setting the default values of
+                attributes [default: false]
+  -enum-methods Generate String and IsValid methods for enumeration types
+                [default: false]
+  -accessors    Generate Get methods of pointer fields, which can be
+                called on nil structs [default: false]
+  -cardinality-comments
+                Comment the field of every child element with its
+                minOccurs and maxOccurs [default: false]
+  -map <xsd_type>=<go_type>
+                Generate a Go type for a built-in XSD type instead of the
+                default one, qualified by its import path if not
+                predeclared, such as
+                xsd:decimal=github.com/shopspring/decimal.Decimal. May be
+                repeated
+  -split        Write a file for every top-level type to the -o directory,
+                named after the type [default: false]
+  -archive <file.zip>
+                Write the files of -split into a zip archive instead of a
+                directory, which needs no -o
+  -recursive    Also read the XSD files in the subdirectories of a directory
+                [default: false]
+  -v            Log every type resolved, complex type recursed into and
+                unresolved reference to stderr [default: false]
+
+goxsd is a tool for generating XML decoding/encoding Go structs, according
+to an XSD schema.
+`
+)
+
+func main() {
+	flag.StringVar(&output, "o", "", "Name of output file")
+	flag.StringVar(&opts.Package, "p", "goxsd", "Name of the Go package")
+	flag.StringVar(&opts.Package, "package", "goxsd", "Name of the Go package")
+	flag.StringVar(&opts.Prefix, "x", "", "Prefix of generated type names")
+	flag.StringVar(&opts.Prefix, "prefix", "", "Prefix of generated type names")
+	flag.BoolVar(&opts.Exported, "e", false, "Generate exported structs")
+	flag.BoolVar(&opts.Unexported, "unexported", false, "Generate unexported structs for all types")
+	flag.BoolVar(&opts.NoNetwork, "no-network", false, "Do not fetch schemas with http(s) locations")
+	flag.BoolVar(&opts.JSON, "json", false, "Generate json struct tags")
+	flag.BoolVar(&opts.Comments, "comments", false, "Comment each type with the XSD type it is generated from")
+	flag.StringVar(&opts.ChardataName, "chardata-name", "Value", "Name of character data fields, empty for the element name")
+	flag.BoolVar(&checkOnly, "check", false, "Report unsupported schema constructs instead of generating code")
+	flag.StringVar(&indent, "indent", "tab", `Indentation, "tab" or a number of spaces`)
+	flag.BoolVar(&opts.Validate, "validate", false, "Generate Validate methods from pattern and length facets")
+	flag.BoolVar(&opts.PatternTypes, "pattern-types", false, "Generate named string types checking the patterns of simple types")
+	flag.BoolVar(&opts.FlattenWrappers, "flatten-wrappers", false, "Replace the fields of list wrapper elements by the list")
+	flag.StringVar(&opts.Choice, "choice", goxsd.ChoiceFlatten, "Choice elements: flatten or interface")
+	flag.StringVar(&opts.Pointers, "use-pointers", goxsd.PointersOptional, "Fields that are pointers: all, optional or none")
+	flag.StringVar(&initialisms, "initialisms", "", "Comma separated initialisms to upper case in Go names")
+	flag.BoolVar(&titleCaseAcronyms, "title-case-acronyms", true, "Upper case initialisms in Go names")
+	flag.BoolVar(&opts.Strict, "strict", false, "Fail on unresolved type names")
+	flag.BoolVar(&opts.Timestamp, "timestamp", false, "Add the time of generation to the header")
+	flag.BoolVar(&opts.Constructors, "constructors", false, "Generate New functions setting attribute defaults")
+	flag.BoolVar(&opts.EnumMethods, "enum-methods", false, "Generate String and IsValid methods for enumeration types")
+	flag.BoolVar(&opts.Accessors, "accessors", false, "Generate Get methods of pointer fields")
+	flag.BoolVar(&opts.CardinalityComments, "cardinality-comments", false, "Comment the fields of child elements with their minOccurs and maxOccurs")
+	flag.BoolVar(&split, "split", false, "Write a file per top-level type to the -o directory")
+	flag.StringVar(&archive, "archive", "", "Zip archive to write the files of -split into")
+	flag.BoolVar(&opts.Recursive, "recursive", false, "Also read the XSD files in subdirectories")
+	flag.Var(typeMap{&opts.TypeMap}, "map", "Go type of a built-in XSD type, as xsd_type=go_type")
+	flag.Var(stringList{&opts.FieldTags}, "fieldtag", "Struct tag key repeating the xml tags")
+	flag.BoolVar(&verbose, "v", false, "Log how the schema is built to stderr")
+	flag.Parse()
+
+	// Allow options to follow the XSD file as well
+	args := flag.Args()
+	if len(args) > 1 {
+		flag.CommandLine.Parse(args[1:])
+		args = append([]string{args[0]}, flag.Args()...)
+	}
+
+	if len(args) != 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	xsdFile := args[0]
+
+	if indent != "tab" {
+		n, err := strconv.Atoi(indent)
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Invalid indent %q, want tab or a number of spaces\n", indent)
+			os.Exit(1)
+		}
+		opts.Indent = n
+	}
+
+	if verbose {
+		verboseLog.SetOutput(os.Stderr)
+		opts.Log = verboseLog.Writer()
+	}
+	if xsdFile == "-" {
+		verboseLog.Print("reading stdin")
+	} else {
+		verboseLog.Printf("reading %s", xsdFile)
+	}
+
+	opts.NoInitialisms = !titleCaseAcronyms
+	for _, w := range strings.Split(initialisms, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			opts.Initialisms = append(opts.Initialisms, w)
+		}
+	}
+
+	if checkOnly {
+		var reports []string
+		var err error
+		if xsdFile == "-" {
+			reports, err = goxsd.CheckFrom(os.Stdin, opts)
+		} else {
+			reports, err = goxsd.Check(xsdFile, opts)
+		}
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		for _, r := range reports {
+			fmt.Println(r)
+		}
+		if len(reports) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts.Warnings = os.Stderr
+	if split || archive != "" {
+		generateFiles(xsdFile)
+		return
+	}
+
+	// Generate into a buffer first, so that a failing generation never
+	// leaves a truncated output file behind.
+	var buf bytes.Buffer
+	var err error
+	if xsdFile == "-" {
+		err = goxsd.GenerateFrom(&buf, os.Stdin, opts)
+	} else {
+		err = goxsd.Generate(&buf, xsdFile, opts)
+	}
+	if err != nil {
+		reportFailure(os.Stderr, &buf, err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		if _, err := buf.WriteTo(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not write output:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	verboseLog.Printf("writing %s", output)
+	out, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create or truncate output file %s: %s\n", output, err)
+		os.Exit(1)
+	}
+	_, err = buf.WriteTo(out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		fmt.Fprintf(os.Stderr, "Could not write output file %s: %s\n", output, err)
+		os.Exit(1)
+	}
+}
+
+// reportFailure reports a failed generation to w. The generated source src
+// is reported too if it could not be formatted, to help debugging, but not
+// when generation stopped halfway through it.
+func reportFailure(w io.Writer, src *bytes.Buffer, err error) {
+	if errors.Is(err, goxsd.ErrFormat) {
+		src.WriteTo(w)
+	}
+	fmt.Fprintln(w, "Code generation failed:", err.Error())
+}
+
+// generateFiles writes a file for every top-level type to the output
+// directory, which is created if needed, or to the archive.
+func generateFiles(xsdFile string) {
+	if archive != "" && output != "" {
+		fmt.Fprintln(os.Stderr, "-archive cannot be combined with -o")
+		os.Exit(1)
+	}
+	if archive == "" && output == "" {
+		fmt.Fprintln(os.Stderr, "-split needs an output directory given with -o")
+		os.Exit(1)
+	}
+
+	var files map[string][]byte
+	var err error
+	if xsdFile == "-" {
+		files, err = goxsd.GenerateFilesFrom(os.Stdin, opts)
+	} else {
+		files, err = goxsd.GenerateFiles(xsdFile, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Code generation failed:", err.Error())
+		os.Exit(1)
+	}
+
+	if archive != "" {
+		verboseLog.Printf("writing %s", archive)
+		if err := writeArchive(archive, files); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write archive %s: %s\n", archive, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(output, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not create output directory:", err.Error())
+		os.Exit(1)
+	}
+	for name, src := range files {
+		path := filepath.Join(output, name)
+		verboseLog.Printf("writing %s", path)
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not write output file %s: %s\n", path, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// archiveTime is the modification time of every file of an archive, the
+// earliest that zip can represent, so that the archive of the same files is
+// the same on every run.
+var archiveTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// writeArchive writes files to a zip archive at path, sorted by name. The
+// archive is built in memory first, so that a failure never leaves a
+// truncated archive behind.
+func writeArchive(path string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveTime}
+		h.SetMode(0644)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// stringList is a repeatable flag of strings.
+type stringList struct {
+	l *[]string
+}
+
+func (s stringList) String() string {
+	if s.l == nil {
+		return ""
+	}
+	return strings.Join(*s.l, ",")
+}
+
+func (s stringList) Set(v string) error {
+	*s.l = append(*s.l, v)
+	return nil
+}
+
+// typeMap is a repeatable flag of XSD types mapped to Go types.
+type typeMap struct {
+	m *map[string]string
+}
+
+func (t typeMap) String() string {
+	if t.m == nil {
+		return ""
+	}
+	var pairs []string
+	for k, v := range *t.m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (t typeMap) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return fmt.Errorf("want xsd_type=go_type, got %q", s)
+	}
+	if *t.m == nil {
+		*t.m = make(map[string]string)
+	}
+	(*t.m)[s[:i]] = s[i+1:]
+	return nil
+}
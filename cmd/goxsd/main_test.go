@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/scottjbarr/goxsd"
+)
+
+// TestMain runs the command itself when the tests run the test binary as
+// goxsd, see runGoxsd.
+func TestMain(m *testing.M) {
+	if os.Getenv("GOXSD_RUN_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runGoxsd runs the goxsd command with the given arguments, and returns what
+// it wrote to stderr, and its error if it failed.
+func runGoxsd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GOXSD_RUN_MAIN=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+func TestReportFailure(t *testing.T) {
+	src := "type note struct {"
+	for _, tt := range []struct {
+		err        error
+		wantSource bool
+	}{
+		{fmt.Errorf("%w: expected '}'", goxsd.ErrFormat), true},
+		{errors.New("template: bad field"), false},
+	} {
+		var out bytes.Buffer
+		reportFailure(&out, bytes.NewBufferString(src), tt.err)
+		if got := strings.Contains(out.String(), src); got != tt.wantSource {
+			t.Errorf("Reporting %q wrote the source: %v, want %v\n%s", tt.err, got, tt.wantSource, out.String())
+		}
+		if !strings.Contains(out.String(), "Code generation failed: "+tt.err.Error()) {
+			t.Errorf("Missing the error %q in the report:\n%s", tt.err, out.String())
+		}
+	}
+}
+
+func TestOutputFileError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "missing", "note.go")
+
+	stderr, err := runGoxsd(t, "-o", output, "../../testdata/nested.xsd")
+	if err == nil {
+		t.Fatal("Expected goxsd to fail writing into a missing directory")
+	}
+	// Along with the error of os.Create
+	want := "Could not create or truncate output file " + output + ": open " + output + ": "
+	if !strings.Contains(stderr, want) {
+		t.Errorf("goxsd reported\n%s\nwant %s", stderr, want)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The same files, inserted in different orders
+	names := []string{"order.go", "invoice.go", "address.go"}
+	var archives [][]byte
+	for i := range names {
+		files := make(map[string][]byte)
+		for j := range names {
+			name := names[(i+j)%len(names)]
+			files[name] = []byte("package test\n\n// " + name + "\n")
+		}
+		path := filepath.Join(dir, fmt.Sprintf("types%d.zip", i))
+		if err := writeArchive(path, files); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, b)
+	}
+	for i, b := range archives[1:] {
+		if !bytes.Equal(b, archives[0]) {
+			t.Errorf("Archive %d differs from archive 0", i+1)
+		}
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(archives[0]), int64(len(archives[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range r.File {
+		got = append(got, f.Name)
+		if _, offset := f.Modified.Zone(); !f.Modified.Equal(archiveTime) || offset != 0 {
+			t.Errorf("%s modified at %s, want %s", f.Name, f.Modified, archiveTime)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "package test\n\n// " + f.Name + "\n"; string(content) != want {
+			t.Errorf("%s holds %q, want %q", f.Name, content, want)
+		}
+	}
+	if want := []string{"address.go", "invoice.go", "order.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Archive entries are %q, want %q", got, want)
+	}
+}
+
+func TestArchiveWithOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "types.zip")
+
+	stderr, err := runGoxsd(t, "-archive", archive, "-o", dir, "../../testdata/nested.xsd")
+	if err == nil {
+		t.Fatal("Expected goxsd to reject -archive with -o")
+	}
+	if want := "-archive cannot be combined with -o"; !strings.Contains(stderr, want) {
+		t.Errorf("goxsd reported\n%s\nwant %s", stderr, want)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Errorf("Unexpected archive written along with the error: %v", err)
+	}
+}
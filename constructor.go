@@ -0,0 +1,120 @@
+package goxsd
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Constructor of a struct with attribute defaults, generated with
+// Options.Constructors
+var constructor = `{{ define "Constructor" }}{{ with constructor . }}
+{{ printf "// %s returns a new %s with the default values of its attributes.\n" .Name .Type }}{{ printf "func %s() *%s {\n" .Name .Type }}{{ printf "v := &%s{}\n" .Type }}{{ range $d := .Defaults }}{{ if $d.Pointer }}{{ printf "v.%s = new(%s)\n*v.%s = %s\n" $d.Field $d.Type $d.Field $d.Value }}{{ else }}{{ printf "v.%s = %s\n" $d.Field $d.Value }}{{ end }}{{ end }}return v
+}
+{{ end }}{{ end }}`
+
+// construction is the data of the constructor of a struct.
+type construction struct {
+	Name, Type string
+	Defaults   []attrDefault
+}
+
+// attrDefault is the default value of an attribute field.
+type attrDefault struct {
+	Field   string
+	Type    string
+	Value   string // Go literal of the value
+	Pointer bool
+}
+
+// construction returns the constructor of the struct generated for e.
+func (g generator) construction(e *xmlTree, typeName func(string) string) construction {
+	typ := typeName(structName(e))
+	c := construction{Name: "New" + typ, Type: typ}
+	if !g.exported {
+		c.Name = "new" + strings.Title(typ)
+	}
+	for i, a := range e.Attribs {
+		value, ok := attrDefaultValue(a)
+		if !ok {
+			continue
+		}
+		c.Defaults = append(c.Defaults, attrDefault{
+			Field:   g.attrField(e, i).Field,
+			Type:    a.Type,
+			Value:   value,
+			Pointer: g.attrPointer(a),
+		})
+	}
+	return c
+}
+
+// constructed reports whether the struct generated for e gets a
+// constructor.
+func constructed(e *xmlTree) bool {
+	if enumType(e) {
+		return false
+	}
+	for _, a := range e.Attribs {
+		if _, ok := attrDefaultValue(a); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// attrDefaultValue returns the Go literal of the value an attribute takes
+// when absent, which is its fixed value, or else its default. Values of
+// types without literals, and values that are not valid for their type,
+// are left out.
+func attrDefaultValue(a xmlAttrib) (string, bool) {
+	value := a.Fixed
+	if value == "" {
+		value = a.Default
+	}
+	if value == "" {
+		return "", false
+	}
+	return goLiteral(a.Type, value)
+}
+
+// goLiteral returns the Go literal of an XSD value of a Go type, in its
+// canonical form, so that values such as 08 do not become invalid octal
+// literals. It reports false for types without literals, and for values
+// not valid for their type, or without a Go literal, such as INF.
+func goLiteral(typ, value string) (string, bool) {
+	switch typ {
+	case "string":
+		return strconv.Quote(value), true
+	case "bool":
+		switch value {
+		case "true", "1":
+			return "true", true
+		case "false", "0":
+			return "false", true
+		}
+	case "int", "int8", "int16", "int32", "int64":
+		if n, err := strconv.ParseInt(value, 10, bitSize(typ)); err == nil {
+			return strconv.FormatInt(n, 10), true
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		if n, err := strconv.ParseUint(value, 10, bitSize(typ)); err == nil {
+			return strconv.FormatUint(n, 10), true
+		}
+	case "float32", "float64":
+		if f, err := strconv.ParseFloat(value, bitSize(typ)); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, bitSize(typ)), true
+		}
+	}
+	return "", false
+}
+
+// bitSize returns the size in bits of a numeric Go type, which is 64 for
+// the sizes that depend on the platform.
+func bitSize(typ string) int {
+	n, err := strconv.Atoi(strings.TrimLeft(typ, "uintfloat"))
+	if err != nil {
+		return 64
+	}
+	return n
+}
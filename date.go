@@ -0,0 +1,81 @@
+package goxsd
+
+// Type of the values of xsd:date, which time.Time does not decode, as it
+// only parses RFC 3339 timestamps. It embeds the time.Time of the day, and
+// decodes and encodes the date as text.
+var date = `{{ define "Date" }}
+{{ printf "// %s is an xsd:date, such as 2006-01-02, at midnight of the day. The time\n" .Type }}// zone of the date, if it has one, is kept in its location.
+{{ printf "type %s struct {\ntime.Time\n}\n" .Type }}
+// UnmarshalText decodes a date, with or without a time zone.
+{{ printf "func (d *%s) UnmarshalText(text []byte) error {\n" .Type }}s := strings.TrimSpace(string(text))
+{{ printf "layout := %q\n" .Layout }}if len(s) > len(layout) {
+layout += "Z07:00"
+}
+t, err := time.Parse(layout, s)
+if err != nil {
+return err
+}
+d.Time = t
+return nil
+}
+
+// MarshalText encodes the date without its time zone.
+{{ printf "func (d %s) MarshalText() ([]byte, error) {\n" .Type }}{{ printf "return []byte(d.Format(%q)), nil\n" .Layout }}}
+
+// String returns the date without its time zone, as MarshalText encodes it.
+{{ printf "func (d %s) String() string {\n" .Type }}{{ printf "return d.Format(%q)\n" .Layout }}}
+{{ if .JSON }}
+// MarshalJSON encodes the date as a JSON string.
+{{ printf "func (d %s) MarshalJSON() ([]byte, error) {\n" .Type }}return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the date from a JSON string.
+{{ printf "func (d *%s) UnmarshalJSON(data []byte) error {\n" .Type }}var s string
+if err := json.Unmarshal(data, &s); err != nil {
+return err
+}
+return d.UnmarshalText([]byte(s))
+}
+{{ end }}{{ end }}`
+
+// dateType is the type of xmlTree values of xsd:date. It is not a Go type,
+// but stands for the type generated for dates, under the name dateName.
+const dateType = "xsd:date"
+
+// dateName is the name of the type generated for xsd:date values.
+const dateName = "xsdDate"
+
+// dateData is the data of the type generated for xsd:date values.
+type dateData struct {
+	Type   string
+	Layout string // layout of time.Format of the date without a time zone
+	JSON   bool
+}
+
+// dateOf returns the data of the type generated for xsd:date values.
+func (g generator) dateOf() dateData {
+	g.used.add("strings", "time")
+	if g.json {
+		g.used.add("encoding/json")
+	}
+	return dateData{Type: g.typeName(dateName), Layout: "2006-01-02", JSON: g.json}
+}
+
+// usesDate reports whether the struct generated for e has a field of an
+// xsd:date value, or of a list of them.
+func usesDate(e *xmlTree) bool {
+	if e.Type == dateType {
+		return true
+	}
+	for _, c := range e.Children {
+		if c.Type == dateType {
+			return true
+		}
+	}
+	for _, a := range e.Attribs {
+		if a.Type == dateType {
+			return true
+		}
+	}
+	return false
+}
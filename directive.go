@@ -0,0 +1,67 @@
+package goxsd
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// directivePrefix starts the words of the appinfo of an element that are
+// directives to goxsd. Other words, such as the hints of other tools, are
+// ignored.
+const directivePrefix = "goxsd:"
+
+// directives are the directives of the appinfo of an element, as in
+// <appinfo>goxsd:type=github.com/shopspring/decimal.Decimal</appinfo>.
+type directives struct {
+	// Go type of the field of the element, which is predeclared or
+	// qualified by its import path, and replaces the type of the element
+	Type string
+	// Go name of the field of the element
+	Name string
+}
+
+// directives returns the directives of the appinfo of an element. The
+// package of a qualified type is added to b.packages. Invalid directives are
+// recorded in b.invalid, and left out.
+func (b builder) directives(e xsdElement) directives {
+	var d directives
+	for _, info := range e.AppInfo {
+		for _, w := range strings.Fields(info) {
+			if !strings.HasPrefix(w, directivePrefix) {
+				continue
+			}
+			if err := b.directive(&d, strings.TrimPrefix(w, directivePrefix)); err != nil {
+				if _, ok := b.invalid[err.Error()]; !ok {
+					b.invalid[err.Error()] = *b.building
+				}
+			}
+		}
+	}
+	return d
+}
+
+// directive sets the directive given as key=value in d.
+func (b builder) directive(d *directives, kv string) error {
+	i := strings.Index(kv, "=")
+	if i < 0 {
+		return fmt.Errorf("directive %s%s has no value", directivePrefix, kv)
+	}
+	key, value := kv[:i], kv[i+1:]
+	switch key {
+	case "type":
+		typ, err := qualifiedType(value, b.packages)
+		if err != nil {
+			return fmt.Errorf("directive %stype: %v", directivePrefix, err)
+		}
+		d.Type = typ
+	case "name":
+		if !token.IsIdentifier(value) || !token.IsExported(value) {
+			return fmt.Errorf("directive %sname: %s is not an exported Go identifier", directivePrefix, value)
+		}
+		d.Name = value
+	default:
+		return fmt.Errorf("unknown directive %s%s", directivePrefix, key)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package goxsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// String and IsValid methods of an enumeration type, generated with
+// Options.EnumMethods
+var enumMethods = `{{ define "EnumMethods" }}{{ with enumMethods . }}
+{{ printf "// String returns the value of a %s as it is in XML.\n" .Type }}{{ printf "func (v %s) String() string {\n" .Type }}{{ printf "return %s\n}\n" .String }}
+{{ printf "// IsValid reports whether a %s is one of the values enumerated by its XSD\n" .Type }}// type.
+{{ printf "func (v %s) IsValid() bool {\n" .Type }}switch v {
+{{ printf "case %s:\n" (join .Consts ", ") }}return true
+}
+return false
+}
+{{ end }}{{ end }}`
+
+// enumConstant is a constant of an enumeration type.
+type enumConstant struct {
+	Name  string
+	Value string // Go literal
+}
+
+// enumConsts returns the constants of the enumeration type generated for e.
+// Constants are named after the type and their value, and values whose
+// names are taken, such as values that only differ in case or sign, get a
+// number. Values without a Go literal of the base type, and values equal to
+// one before, are left out, as they cannot be constants of their own.
+func (g generator) enumConsts(e *xmlTree) []enumConstant {
+	typ := g.typeName(structName(e))
+	names := make(map[string]struct{})
+	values := make(map[string]struct{})
+	var consts []enumConstant
+	for _, v := range e.Enums {
+		lit, ok := goLiteral(e.Type, v)
+		if _, dup := values[lit]; !ok || dup {
+			continue
+		}
+		values[lit] = struct{}{}
+
+		id := identifier(g.names().lintTitle(v))
+		if id == "" {
+			id = "Empty"
+		}
+		name := typ + id
+		for n := 2; ; n++ {
+			if _, ok := names[name]; !ok {
+				break
+			}
+			name = typ + id + strconv.Itoa(n)
+		}
+		names[name] = struct{}{}
+		consts = append(consts, enumConstant{Name: name, Value: lit})
+	}
+	return consts
+}
+
+// enumMethodsData is the data of the methods of an enumeration type.
+type enumMethodsData struct {
+	Type   string
+	String string // Go expression of the string value of v
+	Consts []string
+}
+
+// enumMethodsOf returns the data of the methods of the enumeration type
+// generated for e.
+func (g generator) enumMethodsOf(e *xmlTree, typeName func(string) string) enumMethodsData {
+	m := enumMethodsData{Type: typeName(structName(e)), String: enumString(e.Type)}
+	for _, c := range g.enumConsts(e) {
+		m.Consts = append(m.Consts, c.Name)
+	}
+	if e.Type != "string" {
+		g.used.add("strconv")
+	}
+	return m
+}
+
+// enumString returns the Go expression formatting a value v of an
+// enumeration type with the given base type as a string.
+func enumString(base string) string {
+	switch {
+	case base == "string":
+		return "string(v)"
+	case strings.HasPrefix(base, "int"):
+		return "strconv.FormatInt(int64(v), 10)"
+	case strings.HasPrefix(base, "uint"):
+		return "strconv.FormatUint(uint64(v), 10)"
+	}
+	return fmt.Sprintf("strconv.FormatFloat(float64(v), 'g', -1, %s)", strings.TrimPrefix(base, "float"))
+}
@@ -1,31 +1,47 @@
-package main
+package goxsd
 
 import (
 	"bytes"
 	"fmt"
+	goparser "go/parser"
+	"go/printer"
+	"go/token"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/tools/imports"
 )
 
 var (
 	// Struct field generated from an element attribute
-	attr = `{{ define "Attr" }}{{ printf "  %s " (lintTitle .Name) }}{{ printf "%s ` + "`xml:\\\"%s,attr\\\"`" + `" (lint .Type) .Name }}
+	attr = `{{ define "Attr" }}{{ doc (attrDoc .Attrib) }}{{ printf "  %s %s%s %s" .Field (attrPointer .Attrib) (goType .Attrib.Type) (attrTag .Attrib .Field) }}
 {{ end }}`
 
 	// Struct field generated from an element child element
-	child = `{{ define "Child" }}{{ printf "  %s " (lintTitle .Name) }}{{ if .List }}[]{{ end }}{{ printf "%s ` + "`xml:\\\"%s\\\"`" + `" (typeName (fieldType .)) .Name }}
+	child = `{{ define "Child" }}{{ doc (childDoc .) }}{{ printf "  %s " (childField .) }}{{ if .List }}[]{{ else if childPointer . }}*{{ end }}{{ if simpleList . }}{{ listType .Type }}{{ else }}{{ fieldType . }}{{ end }}{{ printf " %s" (childTag .) }}{{ with cardinality . }}{{ printf " %s" . }}{{ end }}
 {{ end }}`
 
 	// Struct field generated from the character data of an element
-	cdata = `{{ define "Cdata" }}{{ printf "%s %s ` + "`xml:\\\",chardata\\\"`" + `" (lintTitle .Name) (lint .Type) }}
+	cdata = `{{ define "Cdata" }}{{ printf "%s " (cdataField .) }}{{ if .SimpleList }}{{ listType .Type }}{{ else }}{{ goType .Type }}{{ end }}{{ printf " %s" (cdataTag .) }}
 {{ end }}`
 
 	// Struct generated from a non-trivial element (with children and/or attributes)
-	elem = `{{ printf "// %s is generated from an XSD element\ntype %s struct {\n" (typeName .Name) (typeName .Name) }}{{ range $a := .Attribs }}{{ template "Attr" $a }}{{ end }}{{ range $c := .Children }}{{ template "Child" $c }}{{ end }} {{ if .Cdata }}{{ template "Cdata" . }}{{ end }} }
+	elem = `{{ printf "// %s is generated from an XSD element\n" (typeName (structName .)) }}{{ with structDoc . }}//
+{{ . }}{{ end }}{{ with source . (structName .) }}//
+{{ . }}{{ end }}{{ printf "type %s struct {\n" (typeName (structName .)) }}{{ if .Root }}{{ use "encoding/xml" }}{{ printf "XMLName xml.Name %s\n" (rootTag .) }}{{ end }}{{ range $i, $a := .Attribs }}{{ template "Attr" (attrField $ $i) }}{{ end }}{{ if .AnyAttrs }}{{ use "encoding/xml" }}{{ printf "AnyAttrs []xml.Attr %s\n" (anyAttrsTag) }}{{ end }}{{ range $c := .Children }}{{ if not $c.Choice }}{{ template "Child" $c }}{{ end }}{{ end }}{{ with choice . }}{{ printf "%s\n%s %s%s %s\n" (choiceDoc .) .Field (choiceSlice .) .Interface (choiceTag) }}{{ end }} {{ if .Cdata }}{{ template "Cdata" . }}{{ end }}{{ if .InnerXML }}{{ printf "InnerXML string %s\n" (innerXMLTag) }}{{ end }} }
 `
+
+	// Named type and constants generated from a simple type with enumeration facets
+	enum = `{{ define "Enum" }}{{ $type := typeName (structName .) }}{{ printf "// %s is generated from an XSD enumeration\n" $type }}{{ with structDoc . }}//
+{{ . }}{{ end }}{{ with source . (structName .) }}//
+{{ . }}{{ end }}{{ printf "type %s %s\n\n" $type (goType .Type) }}const (
+{{ range $c := enumConsts . }}{{ printf "  %s %s = %s\n" $c.Name $type $c.Value }}{{ end }})
+{{ end }}`
 )
 
 var (
@@ -33,6 +49,7 @@ var (
 	// https://github.com/golang/lint/blob/4946cea8b6efd778dc31dc2dbeb919535e1b7529/lint.go#L698-L738
 	//
 	initialismPairs = []string{
+		"Acl", "ACL",
 		"Api", "API",
 		"Ascii", "ASCII",
 		"Cpu", "CPU",
@@ -67,51 +84,280 @@ var (
 		"Utf8", "UTF8",
 		"Vm", "VM",
 		"Xml", "XML",
+		"Xmpp", "XMPP",
 		"Xsrf", "XSRF",
 		"Xss", "XSS",
 	}
 
-	initialisms = strings.NewReplacer(initialismPairs...)
+	initialisms = newInitialisms(nil)
 )
 
+// initialismSet maps the title cased form of initialisms, such as "Id", to
+// their upper case form.
+type initialismSet map[string]string
+
+// newInitialisms returns the initialisms of golint, extended by the given
+// ones, in any case.
+func newInitialisms(extra []string) initialismSet {
+	s := make(initialismSet)
+	for i := 0; i+1 < len(initialismPairs); i += 2 {
+		s[initialismPairs[i]] = initialismPairs[i+1]
+	}
+	for _, w := range extra {
+		s[strings.Title(strings.ToLower(w))] = strings.ToUpper(w)
+	}
+	return s
+}
+
+// Replace upper cases the title cased words of name that are initialisms.
+// Words are separated by case changes, as in "httpUrl", and by anything
+// that is not a letter or digit. Initialisms within a word, such as the
+// "Id" of "Identity", are kept.
+func (s initialismSet) Replace(name string) string {
+	var b bytes.Buffer
+	rs := []rune(name)
+	start := 0
+	for i := 1; i <= len(rs); i++ {
+		if i < len(rs) && !wordBoundary(rs, i) {
+			continue
+		}
+		w := string(rs[start:i])
+		if u, ok := s[w]; ok {
+			w = u
+		}
+		b.WriteString(w)
+		start = i
+	}
+	return b.String()
+}
+
+// wordBoundary reports whether a new word starts at rs[i]. An upper case
+// letter starts a word after a lower case letter or digit, and so does the
+// last one of a run of upper case letters followed by a lower case one, as
+// the "U" of "HTTPUrl".
+func wordBoundary(rs []rune, i int) bool {
+	prev, cur := rs[i-1], rs[i]
+	if nameSeparator(prev) || nameSeparator(cur) {
+		return true
+	}
+	if !unicode.IsUpper(cur) {
+		return false
+	}
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1])
+}
+
+func (s initialismSet) lint(name string) string {
+	return dashToCamel(squish(s.Replace(name)))
+}
+
+func (s initialismSet) lintTitle(name string) string {
+	return s.lint(strings.Title(name))
+}
+
+func (s initialismSet) fieldName(name string) string {
+	return leadingLetter(s.lintTitle(name), "X")
+}
+
+// commentWidth is the line width at which generated doc comments wrap.
+const commentWidth = 80
+
 // Generator is responsible for generating Go structs based on a given XML
 // schema tree.
 type generator struct {
 	pkg      string
 	prefix   string
 	exported bool
+	json     bool // also generate json struct tags
+	// keys of struct tags repeating the xml tags
+	fieldTags []string
+
+	// lower case the type names that start with an upper case letter
+	unexported bool
+	// name of chardata fields, or empty to name them after their element
+	cdataName string
+	// comment types with the XSD construct they are generated from
+	comments bool
+	// number of spaces to indent with, or 0 to indent with tabs
+	indent int
+	// generate Validate methods checking pattern and length facets
+	validate bool
+	// generate constructors setting the defaults of attributes
+	constructors bool
+	// generate String and IsValid methods for enumeration types
+	enumMethods bool
+	// generate getters of pointer fields that can be called on nil structs
+	accessors bool
+	// which fields are pointers, one of the Pointers* modes
+	pointers string
+	// initialisms upper cased in Go names, or nil for those of golint, and
+	// empty for none
+	initialisms initialismSet
+	// file name of the schema, and the time of generation, named in the
+	// header if not empty
+	source, timestamp string
+	// import paths of the packages of types mapped with Options.TypeMap,
+	// by package name
+	packages map[string]string
 
 	types map[string]struct{}
+	// import paths that the source generated for the current file refers
+	// to
+	used importSet
+
+	// Go field names of children, and of the attributes of each element,
+	// made unique within their struct
+	fields     map[*xmlTree]string
+	attrFields map[*xmlTree][]string
+}
+
+// attrField is an attribute together with the name of its Go field.
+type attrField struct {
+	Attrib xmlAttrib
+	Field  string
 }
 
 func (g generator) do(out io.Writer, roots []*xmlTree) error {
-	g.types = make(map[string]struct{})
+	tt, err := g.prepare()
+	if err != nil {
+		return err
+	}
 
-	tt, err := prepareTemplates(g.prefix, g.exported)
+	var body bytes.Buffer
+	for _, e := range roots {
+		if err := g.execute(e, tt, &body, nil); err != nil {
+			return err
+		}
+	}
+	return g.file(out, g.used.take(), body.Bytes())
+}
+
+// doSplit is like do, but generates a file for every root, and for every
+// named XSD type, named after its type, holding the inline types generated
+// for it that no previous file has. Roots without any such types get no
+// file.
+func (g generator) doSplit(roots []*xmlTree) (map[string][]byte, error) {
+	if g.pkg == "" {
+		return nil, fmt.Errorf("files cannot be generated without a package name")
+	}
+	tt, err := g.prepare()
 	if err != nil {
-		return fmt.Errorf("could not prepare templates: %s", err)
+		return nil, err
 	}
 
-	var res bytes.Buffer
+	files := make(map[string][]byte)
+	for _, e := range splitTypes(roots) {
+		var body bytes.Buffer
+		var emitted []*xmlTree
+		if err := g.execute(e, tt, &body, &emitted); err != nil {
+			return nil, err
+		}
+		// Only the types of this file need imports
+		imps := g.used.take()
+		if len(emitted) == 0 {
+			continue
+		}
 
-	if g.pkg != "" {
-		fmt.Fprintf(&res, "// generated by goxsd; DO NOT EDIT\n\npackage %s\n\n", g.pkg)
+		name := strings.ToLower(g.typeName(structName(e)))
+		file := name + ".go"
+		for n := 2; files[file] != nil; n++ {
+			file = name + strconv.Itoa(n) + ".go"
+		}
+		var out bytes.Buffer
+		if err := g.file(&out, imps, body.Bytes()); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		files[file] = out.Bytes()
 	}
+	return files, nil
+}
 
+// splitTypes returns the roots, each followed by the trees of the named XSD
+// types they contain, which doSplit generates in files of their own.
+func splitTypes(roots []*xmlTree) []*xmlTree {
+	var types []*xmlTree
+	var walk func(e *xmlTree)
+	walk = func(e *xmlTree) {
+		for _, c := range e.Children {
+			if generatedType(c) {
+				if namedType(c) {
+					types = append(types, c)
+				}
+				walk(c)
+			}
+		}
+	}
 	for _, e := range roots {
-		if err := g.execute(e, tt, &res); err != nil {
-			return err
+		types = append(types, e)
+		walk(e)
+	}
+	return types
+}
+
+// namedType reports whether the type generated for e is that of a named XSD
+// type, rather than an inline type, even one numbered to tell it from
+// another of its element name.
+func namedType(e *xmlTree) bool {
+	return e.TypeName != "" && (e.Source == fmt.Sprintf("complexType '%s'", e.TypeName) || e.Source == fmt.Sprintf("simpleType '%s'", e.TypeName))
+}
+
+// prepare resets the generator for a run, and returns its templates.
+func (g *generator) prepare() (*template.Template, error) {
+	g.types = make(map[string]struct{})
+	g.fields = make(map[*xmlTree]string)
+	g.attrFields = make(map[*xmlTree][]string)
+	g.used = importSet{}
+
+	tt, err := g.prepareTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("could not prepare templates: %w", err)
+	}
+	return tt, nil
+}
+
+// file writes a formatted Go source file with the given generated types, or
+// the types alone without a package name.
+func (g generator) file(out io.Writer, imps []string, body []byte) error {
+	var res bytes.Buffer
+
+	if g.pkg != "" {
+		if g.source != "" {
+			fmt.Fprintf(&res, "// Code generated by goxsd from %s; DO NOT EDIT.\n", g.source)
+		} else {
+			fmt.Fprintf(&res, "// Code generated by goxsd; DO NOT EDIT.\n")
+		}
+		if g.timestamp != "" {
+			fmt.Fprintf(&res, "// Generated at %s.\n", g.timestamp)
+		}
+		fmt.Fprintf(&res, "\npackage %s\n\n", g.pkg)
+		if len(imps) > 0 {
+			fmt.Fprintf(&res, "import (\n")
+			for _, p := range imps {
+				fmt.Fprintf(&res, "\t%q\n", p)
+			}
+			fmt.Fprintf(&res, ")\n\n")
 		}
 	}
+	res.Write(body)
 
+	// The imports are those the types use, so they are only formatted
 	buf, err := imports.Process("", res.Bytes(), &imports.Options{
-		Fragment:  true,
-		Comments:  true,
-		TabIndent: true,
-		TabWidth:  8,
+		Fragment:   true,
+		FormatOnly: true,
+		Comments:   true,
+		TabIndent:  true,
+		TabWidth:   8,
 	})
+	if err == nil && g.indent > 0 {
+		buf, err = indentSpaces(buf, g.indent, g.pkg == "")
+	}
 	if err != nil {
-		return err
+		// Hand out the unformatted source, to help debugging the code
+		// generation
+		io.Copy(out, &res)
+		return fmt.Errorf("%w: %w", ErrFormat, err)
 	}
 
 	if _, err := io.Copy(out, bytes.NewBuffer(buf)); err != nil {
@@ -121,18 +367,98 @@ func (g generator) do(out io.Writer, roots []*xmlTree) error {
 	return nil
 }
 
-func (g generator) execute(root *xmlTree, tt *template.Template, out io.Writer) error {
-	if _, ok := g.types[root.Name]; ok {
+// indentSpaces prints formatted Go source again, indented with n spaces
+// instead of tabs. A fragment without a package clause is printed as a file
+// of a package of its own.
+func indentSpaces(src []byte, n int, fragment bool) ([]byte, error) {
+	const clause = "package p\n\n"
+	if fragment {
+		src = append([]byte(clause), src...)
+	}
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", src, goparser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := (&printer.Config{Mode: printer.UseSpaces, Tabwidth: n}).Fprint(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	if fragment {
+		return bytes.TrimPrefix(buf.Bytes(), []byte(clause)), nil
+	}
+	return buf.Bytes(), nil
+}
+
+// execute generates the types of root and its children, other than those
+// already generated. The trees of the types it generates are appended to
+// emitted, if not nil, in which case the named XSD types of the children are
+// left to be generated on their own.
+func (g generator) execute(root *xmlTree, tt *template.Template, out io.Writer, emitted *[]*xmlTree) error {
+	if _, ok := g.types[structName(root)]; ok {
 		return nil
 	}
-	if err := tt.Execute(out, root); err != nil {
+	g.nameFields(root)
+	if enumType(root) {
+		if err := tt.ExecuteTemplate(out, "Enum", root); err != nil {
+			return err
+		}
+		if g.enumMethods {
+			if err := tt.ExecuteTemplate(out, "EnumMethods", root); err != nil {
+				return err
+			}
+		}
+	} else if patternType(root) {
+		if err := tt.ExecuteTemplate(out, "Pattern", root); err != nil {
+			return err
+		}
+	} else if err := tt.Execute(out, root); err != nil {
 		return err
+	} else {
+		if g.constructors && constructed(root) {
+			if err := tt.ExecuteTemplate(out, "Constructor", root); err != nil {
+				return err
+			}
+		}
+		if g.accessors {
+			if err := tt.ExecuteTemplate(out, "Accessors", root); err != nil {
+				return err
+			}
+		}
+		if g.validate && validated(root) {
+			if err := tt.ExecuteTemplate(out, "Validate", root); err != nil {
+				return err
+			}
+		}
+		if choiceType(root) {
+			if err := tt.ExecuteTemplate(out, "Choice", root); err != nil {
+				return err
+			}
+		}
+		for _, item := range listItems(root) {
+			if _, ok := g.types["[]"+item]; ok {
+				continue
+			}
+			if err := tt.ExecuteTemplate(out, "List", g.listOf(item)); err != nil {
+				return err
+			}
+			g.types["[]"+item] = struct{}{}
+		}
+		if _, ok := g.types[dateType]; !ok && usesDate(root) {
+			if err := tt.ExecuteTemplate(out, "Date", g.dateOf()); err != nil {
+				return err
+			}
+			g.types[dateType] = struct{}{}
+		}
+	}
+	g.types[structName(root)] = struct{}{}
+	if emitted != nil {
+		*emitted = append(*emitted, root)
 	}
-	g.types[root.Name] = struct{}{}
 
 	for _, e := range root.Children {
-		if !primitiveType(e) {
-			if err := g.execute(e, tt, out); err != nil {
+		if generatedType(e) && (emitted == nil || !namedType(e)) {
+			if err := g.execute(e, tt, out, emitted); err != nil {
 				return err
 			}
 		}
@@ -141,27 +467,147 @@ func (g generator) execute(root *xmlTree, tt *template.Template, out io.Writer)
 	return nil
 }
 
-func prepareTemplates(prefix string, exported bool) (*template.Template, error) {
-	typeName := func(name string) string {
-		switch name {
-		case "bool", "string", "int", "float64", "time.Time":
-		default:
-			if prefix != "" {
-				name = prefix + strings.Title(name)
-			}
-			if exported {
-				name = strings.Title(name)
-			}
-			name = lint(name)
-		}
+// goType returns the Go name of a type, which is either built-in or
+// generated.
+func (g generator) goType(name string) string {
+	if name == dateType {
+		return g.typeName(dateName)
+	}
+	if builtinType(name) {
 		return name
 	}
+	return g.typeName(name)
+}
+
+// typeName returns the Go name of a generated type. Unexported names that
+// are taken by the identifiers the generated code uses, such as string or
+// xml, are prefixed like names that do not start with a letter.
+func (g generator) typeName(name string) string {
+	if g.prefix != "" {
+		name = g.prefix + strings.Title(name)
+	}
+	if g.exported {
+		name = strings.Title(name)
+		return leadingLetter(g.names().lint(name), "X")
+	}
+	name = leadingLetter(g.names().lint(name), "x")
+	if g.unexported {
+		name = unexportedName(name)
+	}
+	if _, ok := reservedNames[name]; ok || token.Lookup(name).IsKeyword() {
+		name = "x" + name
+	}
+	return name
+}
+
+// reservedNames are the predeclared identifiers and the package names that
+// unexported type names would shadow.
+var reservedNames = map[string]struct{}{
+	"bool": {}, "byte": {}, "complex64": {}, "complex128": {}, "error": {},
+	"float32": {}, "float64": {}, "int": {}, "int8": {}, "int16": {},
+	"int32": {}, "int64": {}, "rune": {}, "string": {}, "uint": {},
+	"uint8": {}, "uint16": {}, "uint32": {}, "uint64": {}, "uintptr": {},
+	"true": {}, "false": {}, "iota": {}, "nil": {}, "append": {}, "cap": {},
+	"close": {}, "complex": {}, "copy": {}, "delete": {}, "imag": {},
+	"len": {}, "make": {}, "new": {}, "panic": {}, "print": {},
+	"println": {}, "real": {}, "recover": {},
+	"xml": {}, "fmt": {}, "regexp": {}, "utf8": {}, "time": {}, "strings": {},
+	"strconv": {}, "json": {},
+}
+
+func (g generator) prepareTemplates() (*template.Template, error) {
+	typeName := g.typeName
+
+	// source names the XSD construct a type is generated from, if asked
+	// to
+	source := func(e *xmlTree, name string) string {
+		if !g.comments || e.Source == "" {
+			return ""
+		}
+		return fmt.Sprintf("// %s was generated from %s\n", typeName(name), e.Source)
+	}
 
 	fmap := template.FuncMap{
-		"lint":      lint,
-		"lintTitle": lintTitle,
+		"lint":      g.names().lint,
+		"lintTitle": g.names().lintTitle,
+		"fieldName": g.fieldName,
 		"typeName":  typeName,
-		"fieldType": fieldType,
+		"fieldType": func(e *xmlTree) string {
+			return g.useType(g.fieldType(e))
+		},
+		"goType": func(name string) string {
+			return g.useType(g.goType(name))
+		},
+		"use": func(paths ...string) string {
+			g.used.add(paths...)
+			return ""
+		},
+		"structName": structName,
+		"attrTag":    g.attrTag,
+		"childTag":   g.childTag,
+		"cdataTag":   g.cdataTag,
+		"rootTag":    g.rootTag,
+		"anyAttrsTag": func() string {
+			return g.structTag(",any,attr", "AnyAttrs", true)
+		},
+		"innerXMLTag": func() string {
+			return g.structTag(",innerxml", "InnerXML", false)
+		},
+		"cdataField":   g.cdataField,
+		"childField":   g.childField,
+		"childPointer": g.childPointer,
+		"simpleList":   simpleList,
+		"listType":     g.listType,
+		"attrPointer": func(a xmlAttrib) string {
+			if g.attrPointer(a) {
+				return "*"
+			}
+			return ""
+		},
+		"attrField":   g.attrField,
+		"enumConsts":  g.enumConsts,
+		"doc":         doc,
+		"structDoc":   structDoc,
+		"attrDoc":     attrDoc,
+		"childDoc":    childDoc,
+		"cardinality": cardinality,
+		"source":      source,
+		"validation": func(e *xmlTree) (validation, error) {
+			return g.validation(e, typeName)
+		},
+		"patternValidation": func(e *xmlTree) (patternValidation, error) {
+			return g.patternValidation(e, typeName)
+		},
+		"constructor": func(e *xmlTree) construction {
+			return g.construction(e, typeName)
+		},
+		"accessors": func(e *xmlTree) []accessor {
+			return g.accessorsOf(e, typeName)
+		},
+		"choice": func(e *xmlTree) *choiceData {
+			return g.choice(e, typeName)
+		},
+		"choiceDoc": choiceDoc,
+		"choiceSlice": func(c *choiceData) string {
+			if c.List {
+				return "[]"
+			}
+			return ""
+		},
+		"enumMethods": func(e *xmlTree) enumMethodsData {
+			return g.enumMethodsOf(e, typeName)
+		},
+		"join": strings.Join,
+		"choiceElementsTag": func() string {
+			// The elements of the struct's own fields are matched first,
+			// those of its choice collected in order
+			return "`xml:\",any\"`"
+		},
+		"choiceTag": func() string {
+			// Neither encoding/xml nor encoding/json can decode into an
+			// interface; the methods of the struct take care of it
+			return g.tag("-", "-")
+		},
 	}
 
 	tt := template.New("yyy").Funcs(fmap)
@@ -177,49 +623,477 @@ func prepareTemplates(prefix string, exported bool) (*template.Template, error)
 	if _, err := tt.Parse(elem); err != nil {
 		return nil, err
 	}
+	if _, err := tt.Parse(enum); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(validate); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(constructor); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(accessors); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(pattern); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(choice); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(choiceList); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(enumMethods); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(list); err != nil {
+		return nil, err
+	}
+	if _, err := tt.Parse(date); err != nil {
+		return nil, err
+	}
 	return tt, nil
 }
 
-// If this is a chardata field, the field type must point to a
-// struct, even if the element type is a built-in primitive.
-func fieldType(e *xmlTree) string {
+// simpleList reports whether the field of an element is a slice of the
+// item type of its simple list type. The list of an element with attributes
+// is the chardata of its struct instead.
+func simpleList(e *xmlTree) bool {
+	return e.SimpleList && !e.Cdata
+}
+
+// childPointer reports whether the field of a child element, unless it is
+// a list, is a pointer. References to the types of other elements always
+// are, as they may be recursive, and so are nillable elements, for a nil
+// value to be representable.
+func (g generator) childPointer(e *xmlTree) bool {
+	if simpleList(e) {
+		return false
+	}
+	switch g.pointers {
+	case PointersAll:
+		return true
+	case PointersNone:
+		return e.Ref || e.Nillable
+	}
+	return e.Optional || e.Ref || e.Nillable
+}
+
+// attrPointer reports whether the field of an attribute is a pointer.
+// Optional attributes are only omitted when empty, unless all fields are
+// pointers.
+func (g generator) attrPointer(a xmlAttrib) bool {
+	return g.pointers == PointersAll
+}
+
+// attrTag returns the struct tag of the field of an attribute.
+func (g generator) attrTag(a xmlAttrib, field string) string {
+	if a.Optional {
+		return g.structTag(a.Name+",attr,omitempty", field, true)
+	}
+	return g.structTag(a.Name+",attr", field, false)
+}
+
+// childTag returns the struct tag of a field generated from a child element.
+// Top-level elements of a schema with a target namespace are qualified by
+// it.
+func (g generator) childTag(e *xmlTree) string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + " " + name
+	}
+	if e.Wrapper != "" {
+		name = e.Wrapper + ">" + name
+	}
+	if e.Optional {
+		return g.structTag(name+",omitempty", g.childField(e), true)
+	}
+	return g.structTag(name, g.childField(e), false)
+}
+
+// childField returns the Go field name of a child element.
+func (g generator) childField(e *xmlTree) string {
+	if f, ok := g.fields[e]; ok {
+		return f
+	}
+	return g.fieldName(fieldElement(e))
+}
+
+// fieldElement returns the name of the element that names the field of a
+// child element, which is that of its wrapper if flattened.
+func fieldElement(e *xmlTree) string {
+	if e.Wrapper != "" {
+		return e.Wrapper
+	}
+	return e.Name
+}
+
+// attrField returns the i-th attribute of e with the Go name of its field.
+func (g generator) attrField(e *xmlTree, i int) attrField {
+	a := e.Attribs[i]
+	if fs := g.attrFields[e]; i < len(fs) {
+		return attrField{Attrib: a, Field: fs[i]}
+	}
+	return attrField{Attrib: a, Field: g.fieldName(a.Name)}
+}
+
+// nameFields names the fields of the struct generated for e, so that no two
+// are the same. Children keep their names where possible, attributes that
+// clash with them get an Attr suffix, and any clash left gets a number.
+func (g generator) nameFields(e *xmlTree) {
+	taken := make(map[string]struct{})
+	if e.Root {
+		taken["XMLName"] = struct{}{}
+	}
+	if e.AnyAttrs {
+		taken["AnyAttrs"] = struct{}{}
+	}
+	if e.InnerXML {
+		taken["InnerXML"] = struct{}{}
+	}
 	if e.Cdata {
-		return e.Name
+		taken[g.cdataField(e)] = struct{}{}
+	}
+	if choiceType(e) {
+		taken[choiceField] = struct{}{}
+	}
+
+	unique := func(name string) string {
+		f := name
+		for n := 2; ; n++ {
+			if _, ok := taken[f]; !ok {
+				break
+			}
+			f = name + strconv.Itoa(n)
+		}
+		taken[f] = struct{}{}
+		return f
+	}
+
+	for _, c := range e.Children {
+		name := c.FieldName
+		if name == "" {
+			name = g.fieldName(fieldElement(c))
+		}
+		g.fields[c] = unique(name)
+	}
+	var attrs []string
+	for _, a := range e.Attribs {
+		f := g.fieldName(a.Name)
+		if _, ok := taken[f]; ok {
+			f += "Attr"
+		}
+		attrs = append(attrs, unique(f))
+	}
+	g.attrFields[e] = attrs
+}
+
+// rootTag returns the struct tag of the XMLName field of a top-level
+// element, which is left out of json.
+func (g generator) rootTag(e *xmlTree) string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + " " + name
+	}
+	return g.tag(name, "-")
+}
+
+// cdataTag returns the struct tag of a field holding the character data of
+// an element.
+func (g generator) cdataTag(e *xmlTree) string {
+	return g.structTag(",chardata", g.cdataField(e), false)
+}
+
+// cdataField returns the name of the field holding the character data of an
+// element.
+func (g generator) cdataField(e *xmlTree) string {
+	if g.cdataName != "" {
+		return g.fieldName(g.cdataName)
+	}
+	return g.fieldName(e.Name)
+}
+
+// structTag formats an xml struct tag with the given value, which keeps the
+// original XSD name regardless of how the Go field name is normalized. With
+// json tags enabled, the json key is the Go field name.
+func (g generator) structTag(value, field string, optional bool) string {
+	key := field
+	if optional {
+		key += ",omitempty"
+	}
+	return g.tag(value, key)
+}
+
+// tag returns a struct tag with an xml value, repeated for every key of
+// Options.FieldTags, and a json value if json tags are generated.
+func (g generator) tag(value, json string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "`xml:%q", value)
+	for _, key := range g.fieldTags {
+		fmt.Fprintf(&b, " %s:%q", key, value)
+	}
+	if g.json {
+		fmt.Fprintf(&b, " json:%q", json)
+	}
+	b.WriteString("`")
+	return b.String()
+}
+
+// doc formats XSD documentation as Go comment lines. Line breaks of the
+// documentation are kept, and long lines are wrapped.
+func doc(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+
+	var b bytes.Buffer
+	for _, l := range lines {
+		line := "//"
+		for _, w := range strings.Fields(l) {
+			if len(line)+1+len(w) > commentWidth && line != "//" {
+				b.WriteString(line + "\n")
+				line = "//"
+			}
+			line += " " + w
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// attrDoc returns the documentation of an attribute, followed by the value
+// mandated by the schema, if any.
+func attrDoc(a xmlAttrib) string {
+	text := a.Doc
+	if a.Fixed != "" {
+		text += fmt.Sprintf("\nFixed to %q by the schema.", a.Fixed)
+	} else if a.Default != "" {
+		text += fmt.Sprintf("\nDefaults to %q when absent.", a.Default)
+	}
+	return joinDoc(text, allowedNote(a.Enums))
+}
+
+// childDoc returns the documentation of a child element. Enumerated values
+// that are not generated as constants are listed instead.
+func childDoc(e *xmlTree) string {
+	if enumType(e) {
+		return e.Doc
+	}
+	return joinDoc(e.Doc, allowedNote(e.Enums))
+}
+
+// cardinality returns the line comment of the field of a child element with
+// its minOccurs and maxOccurs, if they were recorded.
+func cardinality(e *xmlTree) string {
+	if e.MinOccurs == "" {
+		return ""
 	}
-	return e.Type
+	return fmt.Sprintf("// minOccurs=%s maxOccurs=%s", e.MinOccurs, e.MaxOccurs)
+}
+
+// allowedNote returns a documentation note listing enumerated values.
+func allowedNote(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return "Allowed values: " + strings.Join(values, ", ") + "."
+}
+
+// structDoc returns the doc comment lines of the type generated for e,
+// taken from the documentation of its XSD type, or of the element itself if
+// the type is inline.
+func structDoc(e *xmlTree) string {
+	if e.TypeDoc != "" || e.TypeName != "" {
+		return doc(e.TypeDoc)
+	}
+	return doc(e.Doc)
+}
+
+// fieldType returns the Go type of the field of a child element. If this is
+// a chardata or enumeration field, the field type must point to a generated
+// type, even if the element type is a built-in primitive. Inline types have
+// no type name but that of their struct.
+func (g generator) fieldType(e *xmlTree) string {
+	if e.Cdata || enumType(e) || patternType(e) || e.Type == "" {
+		return g.typeName(structName(e))
+	}
+	return g.goType(e.Type)
+}
+
+// structName returns the name of the type generated for e. A named XSD type
+// is generated once under its own name, whereas inline types are named
+// after their element.
+func structName(e *xmlTree) string {
+	if e.TypeName != "" {
+		return e.TypeName
+	}
+	return e.Name
 }
 
 func primitiveType(e *xmlTree) bool {
-	if e.Cdata {
+	if e.Cdata || enumType(e) || patternType(e) {
 		return false
 	}
+	return builtinType(e.Type)
+}
+
+// generatedType reports whether e is a child for which a type must be
+// generated. References to types generated for other elements are not.
+func generatedType(e *xmlTree) bool {
+	return !primitiveType(e) && !e.Ref
+}
+
+// enumType reports whether a named type with constants should be generated
+// for the element. The character data of an element with attributes keeps
+// its base type, as do types whose values cannot be constants.
+func enumType(e *xmlTree) bool {
+	return len(e.Enums) > 0 && !e.Cdata && constType(e.Type)
+}
 
-	switch e.Type {
-	case "bool", "string", "int", "float64", "time.Time":
+// builtinType reports whether name is a Go type that is not generated, but
+// provided by the language, the standard library, or the packages of the
+// types of Options.TypeMap, or the type of xsd:date values, which is
+// generated once for all of them.
+func builtinType(name string) bool {
+	switch name {
+	case "bool", "string", "float32", "float64", "[]byte", "time.Time", "time.Duration", dateType,
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64":
 		return true
 	}
-	return false
+	return packageType(name)
+}
+
+// packageType reports whether name is an exported type qualified by the
+// name of its package, such as decimal.Decimal. Generated types never are.
+func packageType(name string) bool {
+	i := strings.Index(name, ".")
+	return i > 0 && token.IsIdentifier(name[:i]) && token.IsExported(name[i+1:]) && token.IsIdentifier(name[i+1:])
+}
+
+// importSet accumulates the import paths that generated source refers to,
+// as it is generated, so that a file imports exactly those.
+type importSet map[string]struct{}
+
+// add adds import paths to the set.
+func (s importSet) add(paths ...string) {
+	for _, p := range paths {
+		s[p] = struct{}{}
+	}
+}
+
+// take returns the sorted import paths of the set, and empties it for the
+// next file.
+func (s importSet) take() []string {
+	var paths []string
+	for p := range s {
+		paths = append(paths, p)
+		delete(s, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// useType adds the import path of the package of a type that the generated
+// source refers to, such as "time" for time.Time, to the imports, and
+// returns the type.
+func (g generator) useType(typ string) string {
+	if !builtinType(typ) {
+		return typ
+	}
+	if i := strings.LastIndex(typ, "."); i > 0 {
+		p := typ[:i]
+		if path, ok := g.packages[p]; ok {
+			p = path
+		}
+		g.used.add(p)
+	}
+	return typ
 }
 
 func lint(s string) string {
-	return dashToCamel(squish(initialisms.Replace(s)))
+	return initialisms.lint(s)
+}
+
+// fieldName returns the exported Go field name of an XSD element or attribute
+// name. encoding/xml can only populate exported fields.
+func fieldName(s string) string {
+	return initialisms.fieldName(s)
+}
+
+// names returns the initialisms of Go names.
+func (g generator) names() initialismSet {
+	if g.initialisms == nil {
+		return initialisms
+	}
+	return g.initialisms
+}
+
+// fieldName is like the fieldName function, with the initialisms of g.
+func (g generator) fieldName(s string) string {
+	return g.names().fieldName(s)
+}
+
+// leadingLetter prepends prefix to a name that does not start with a letter,
+// such as names starting with a digit, which are not valid Go identifiers,
+// or with an underscore, which are never exported. An empty name, left
+// after stripping all separators, becomes the prefix itself.
+func leadingLetter(name, prefix string) string {
+	if r, _ := utf8.DecodeRuneInString(name); name == "" || !unicode.IsLetter(r) {
+		return prefix + name
+	}
+	return name
+}
+
+// unexportedName lower cases the leading upper case letters of a name, but
+// for the one starting the next word, so that initialisms stay in one case:
+// URLType becomes urlType, and URL url.
+func unexportedName(name string) string {
+	rs := []rune(name)
+	n := 0
+	for n < len(rs) && unicode.IsUpper(rs[n]) {
+		n++
+	}
+	if n > 1 && n < len(rs) && unicode.IsLower(rs[n]) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		rs[i] = unicode.ToLower(rs[i])
+	}
+	return string(rs)
 }
 
 func lintTitle(s string) string {
-	return lint(strings.Title(s))
+	return initialisms.lintTitle(s)
 }
 
 func squish(s string) string {
 	return strings.Replace(s, " ", "", -1)
 }
 
-func dashToCamel(name string) string {
-	s := strings.Split(name, "-")
-	if len(s) > 1 {
-		for i := 1; i < len(s); i++ {
-			s[i] = strings.Title(s[i])
+// identifier drops all characters that are not allowed in a Go identifier.
+func identifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
 		}
-		return strings.Join(s, "")
+		return -1
+	}, s)
+}
+
+// dashToCamel joins the words of a name separated by characters that are
+// not allowed in Go identifiers, such as the hyphens and periods allowed in
+// XSD names, into a single camel cased identifier.
+func dashToCamel(name string) string {
+	s := strings.FieldsFunc(name, nameSeparator)
+	for i := 1; i < len(s); i++ {
+		s[i] = strings.Title(s[i])
 	}
-	return name
+	return strings.Join(s, "")
+}
+
+func nameSeparator(r rune) bool {
+	return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
 }
@@ -0,0 +1,15 @@
+module github.com/scottjbarr/goxsd
+
+go 1.21
+
+require (
+	github.com/kr/pretty v0.3.1
+	golang.org/x/tools v0.24.0
+)
+
+require (
+	github.com/kr/text v0.2.0 // indirect
+	github.com/rogpeppe/go-internal v1.9.0 // indirect
+	golang.org/x/mod v0.20.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
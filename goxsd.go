@@ -1,165 +1,1238 @@
+// Package goxsd generates Go structs for decoding and encoding XML documents
+// according to an XSD schema. The goxsd command in cmd/goxsd wraps it.
+//
 // Things not yet implemented:
-// - enforcing use="restricted" on attributes
-// - namespaces
-
-package main
+// - namespaces of local elements, which are always unqualified
+package goxsd
 
 import (
-	"flag"
+	"bytes"
+	"errors"
 	"fmt"
-	"log"
+	"go/token"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-var (
-	output, pckg, prefix string
-	exported             bool
-
-	usage = `Usage: goxsd [options] <xsd_file>
+// Options configure the generated Go source.
+type Options struct {
+	// Package is the name of the generated package. Without one, no
+	// package clause and imports are generated.
+	Package string
+	// Prefix is prepended to the names of the generated types.
+	Prefix string
+	// Exported makes the generated types exported.
+	Exported bool
+	// Unexported makes all generated types unexported, lower casing the
+	// names that start with an upper case letter in the schema, for code
+	// that is internal to its package. The fields stay exported, since
+	// encoding/xml only decodes into exported fields.
+	Unexported bool
+	// JSON adds json struct tags next to the xml tags.
+	JSON bool
+	// FieldTags are the keys of struct tags added next to every xml tag,
+	// with the same value, for codecs that read other keys.
+	FieldTags []string
+	// ChardataName is the name of character data fields, which are named
+	// after their element if empty.
+	ChardataName string
+	// Comments comments each type with the XSD type it is generated from.
+	Comments bool
+	// NoNetwork fails on http(s) schema locations instead of fetching
+	// them.
+	NoNetwork bool
+	// Indent is the number of spaces to indent with. The default of 0
+	// indents with tabs, like gofmt.
+	Indent int
+	// Validate generates a Validate method for every struct with string
+	// fields constrained by pattern or length facets.
+	Validate bool
+	// Pointers is one of the Pointers* modes, which picks the fields that
+	// are pointers. The default of "" is PointersOptional.
+	Pointers string
+	// Initialisms are upper cased in Go names, like the initialisms of
+	// golint, such as ID and URL, which they add to.
+	Initialisms []string
+	// NoInitialisms keeps initialisms in the case of the schema, only
+	// upper casing the first letter of exported names. It cannot be
+	// combined with Initialisms.
+	NoInitialisms bool
+	// Warnings, if not nil, receives a line for every unresolved type
+	// name in the schema, whose values are generated as strings, followed
+	// by a summary line.
+	Warnings io.Writer
+	// Strict fails the generation on unresolved type names.
+	Strict bool
+	// Log, if not nil, receives a line for every type name resolved, every
+	// complex type recursed into and every unresolved reference while
+	// building, prefixed by the top-level definition being built, which
+	// helps tell why a field is generated the way it is.
+	Log io.Writer
+	// Timestamp adds the time of generation to the header of the
+	// generated source, which is otherwise the same on every run.
+	Timestamp bool
+	// Constructors generates a New function for every struct with
+	// attributes that have default or fixed values, returning the struct
+	// with those values set.
+	Constructors bool
+	// TypeMap maps built-in XSD types, such as "decimal" or "xs:decimal",
+	// to the Go types generated for them instead of the default ones. A
+	// Go type is predeclared, or qualified by its import path, such as
+	// "github.com/shopspring/decimal.Decimal".
+	TypeMap map[string]string
+	// PatternTypes generates a named string type for every simple type
+	// restricted by a pattern facet, with a Validate method checking the
+	// pattern, instead of a plain string.
+	PatternTypes bool
+	// FlattenWrappers replaces the field of a child element that only
+	// wraps a list of another element by the list, tagged with the path
+	// through the wrapper, such as xml:"items>item".
+	FlattenWrappers bool
+	// Recursive also parses the XSD files in the subdirectories of a
+	// directory passed to Generate or Check.
+	Recursive bool
+	// EnumMethods generates String and IsValid methods for every
+	// enumeration type.
+	EnumMethods bool
+	// Accessors generates a Get method for every pointer field of a
+	// struct, which returns the zero value of the field, or nil for
+	// structs, when the struct or the field is nil.
+	Accessors bool
+	// Choice is one of the Choice* modes, which picks how the elements of
+	// a choice are generated. The default of "" is ChoiceFlatten.
+	Choice string
+	// CardinalityComments comments the field of every child element with
+	// its minOccurs and maxOccurs.
+	CardinalityComments bool
+}
 
-Options:
-  -o <file>     Destination file [default: stdout]
-  -p <package>  Package name [default: goxsd]
-  -e            Generate exported structs [default: false]
-  -x <prefix>   Struct name prefix [default: ""]
+// The modes of Options.Pointers. Lists are slices in every mode, and fields
+// of elements that refer to the type of another element are pointers in
+// every mode, since the types may be recursive.
+const (
+	// PointersOptional makes the fields of optional elements pointers,
+	// so that absent elements are nil. Optional attributes are values
+	// that are omitted when empty.
+	PointersOptional = "optional"
+	// PointersAll makes the fields of all elements and attributes
+	// pointers.
+	PointersAll = "all"
+	// PointersNone makes no fields pointers. Optional fields are then
+	// omitted when they hold the zero value of their type.
+	PointersNone = "none"
+)
 
-goxsd is a tool for generating XML decoding/encoding Go structs, according
-to an XSD schema.
-`
+// The modes of Options.Choice.
+const (
+	// ChoiceFlatten makes every element of a choice an optional field of
+	// the struct of the choice.
+	ChoiceFlatten = "flatten"
+	// ChoiceInterface makes the choice of a complex type a field of an
+	// interface type, implemented by a type for each element of the
+	// choice. The struct gets UnmarshalXML and MarshalXML methods, which
+	// pick the implementation by the name of the element. Choices within
+	// sequences and groups are still flattened.
+	ChoiceInterface = "interface"
 )
 
-func main() {
-	flag.StringVar(&output, "o", "", "Name of output file")
-	flag.StringVar(&pckg, "p", "goxsd", "Name of the Go package")
-	flag.StringVar(&prefix, "x", "", "Name of the Go package")
-	flag.BoolVar(&exported, "e", false, "Generate exported structs")
-	flag.Parse()
+// ErrFormat is wrapped by the errors of generating source that cannot be
+// formatted, which is then written unformatted.
+var ErrFormat = errors.New("could not format generated source")
+
+// Generate writes Go source for the XSD schema at xsdPath, and the schemas
+// it imports, to w. If xsdPath is a directory, the source is generated for
+// all of its XSD files together. If the generated source cannot be
+// formatted, it is written unformatted along with an error wrapping
+// ErrFormat, to help debugging.
+func Generate(w io.Writer, xsdPath string, opts Options) error {
+	schemas, err := parseXSDPath(xsdPath, opts)
+	if err != nil {
+		return err
+	}
+	return generate(w, schemas, filepath.Base(xsdPath), opts)
+}
+
+// parseXSDPath parses the XSD file at path, or the XSD files in it if it is
+// a directory, and the schemas they import.
+func parseXSDPath(path string, opts Options) ([]xsdSchema, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return parseXSDDir(path, opts.Recursive, opts.NoNetwork)
+	}
+	return parseXSDFile(path, opts.NoNetwork)
+}
+
+// GenerateFrom is like Generate, but reads the XSD schema from r. Relative
+// schema locations of its imports are resolved against the working
+// directory.
+func GenerateFrom(w io.Writer, r io.Reader, opts Options) error {
+	schemas, err := parseXSDReader(r, opts.NoNetwork)
+	if err != nil {
+		return err
+	}
+	return generate(w, schemas, "", opts)
+}
+
+// GenerateFiles is like Generate, but generates a file for every top-level
+// element and named type, holding the inline types generated for it that no
+// previous one has. The files are returned by name, which is that of the
+// type in lower case.
+// Options.Package is required.
+func GenerateFiles(xsdPath string, opts Options) (map[string][]byte, error) {
+	schemas, err := parseXSDPath(xsdPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	gen, roots, err := build(schemas, filepath.Base(xsdPath), opts)
+	if err != nil {
+		return nil, err
+	}
+	return gen.doSplit(roots)
+}
 
-	if len(flag.Args()) != 1 {
-		fmt.Println(usage)
-		os.Exit(1)
+// GenerateFilesFrom is like GenerateFiles, but reads the XSD schema from r,
+// like GenerateFrom.
+func GenerateFilesFrom(r io.Reader, opts Options) (map[string][]byte, error) {
+	schemas, err := parseXSDReader(r, opts.NoNetwork)
+	if err != nil {
+		return nil, err
+	}
+	gen, roots, err := build(schemas, "", opts)
+	if err != nil {
+		return nil, err
 	}
-	xsdFile := flag.Arg(0)
+	return gen.doSplit(roots)
+}
 
-	s, err := parseXSDFile(xsdFile)
+// generate writes Go source for the schemas, which were parsed from the
+// file named source, if not empty.
+func generate(w io.Writer, schemas []xsdSchema, source string, opts Options) error {
+	gen, roots, err := build(schemas, source, opts)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	return gen.do(w, roots)
+}
 
-	out := os.Stdout
-	if output != "" {
-		if out, err = os.Create(output); err != nil {
-			fmt.Println("Could not create or truncate output file:", output)
-			os.Exit(1)
+// build builds the trees of the schemas, and the generator generating Go
+// source for them.
+func build(schemas []xsdSchema, source string, opts Options) (generator, []*xmlTree, error) {
+	switch opts.Pointers {
+	case "", PointersOptional, PointersAll, PointersNone:
+	default:
+		return generator{}, nil, fmt.Errorf("unknown pointer mode %q, want %s, %s or %s",
+			opts.Pointers, PointersOptional, PointersAll, PointersNone)
+	}
+
+	if opts.NoInitialisms && len(opts.Initialisms) > 0 {
+		return generator{}, nil, fmt.Errorf("initialisms cannot be given when they are not upper cased")
+	}
+	if opts.Exported && opts.Unexported {
+		return generator{}, nil, fmt.Errorf("types cannot be both exported and unexported")
+	}
+	for _, key := range opts.FieldTags {
+		if err := checkTagKey(key); err != nil {
+			return generator{}, nil, err
 		}
 	}
 
-	bldr := builder{
-		schemas:    s,
-		complTypes: make(map[string]xsdComplexType),
-		simplTypes: make(map[string]xsdSimpleType),
+	switch opts.Choice {
+	case "", ChoiceFlatten, ChoiceInterface:
+	default:
+		return generator{}, nil, fmt.Errorf("unknown choice mode %q, want %s or %s",
+			opts.Choice, ChoiceFlatten, ChoiceInterface)
+	}
+
+	types, packages, err := typeMap(opts.TypeMap)
+	if err != nil {
+		return generator{}, nil, err
+	}
+
+	b := newBuilder(schemas)
+	b.log = opts.Log
+	b.typeMap = types
+	b.packages = packages
+	b.patternTypes = opts.PatternTypes
+	b.choiceInterface = opts.Choice == ChoiceInterface
+	b.cardinality = opts.CardinalityComments
+	roots, err := b.buildXML()
+	if err != nil {
+		return generator{}, nil, err
+	}
+	if opts.FlattenWrappers {
+		flattenWrappers(roots)
+	}
+	if warnings, refs := b.unresolvedTypes(); refs > 0 {
+		if opts.Warnings != nil {
+			for _, w := range warnings {
+				fmt.Fprintf(opts.Warnings, "warning: %s\n", w)
+			}
+			fmt.Fprintf(opts.Warnings, "%d unresolved type references\n", refs)
+		}
+		if opts.Strict {
+			return generator{}, nil, b.unresolvedError(refs)
+		}
 	}
 
 	gen := generator{
-		pkg:      pckg,
-		prefix:   prefix,
-		exported: exported,
+		pkg:      opts.Package,
+		prefix:   opts.Prefix,
+		exported: opts.Exported,
+		json:     opts.JSON,
+
+		fieldTags: opts.FieldTags,
+
+		unexported: opts.Unexported,
+		cdataName:  opts.ChardataName,
+		comments:   opts.Comments,
+		indent:     opts.Indent,
+		validate:   opts.Validate,
+		pointers:   opts.Pointers,
+		source:     source,
+
+		constructors: opts.Constructors,
+		enumMethods:  opts.EnumMethods,
+		accessors:    opts.Accessors,
+		packages:     packages,
+	}
+	if opts.Timestamp {
+		gen.timestamp = time.Now().UTC().Format(time.RFC3339)
 	}
+	if len(opts.Initialisms) > 0 {
+		gen.initialisms = newInitialisms(opts.Initialisms)
+	}
+	if opts.NoInitialisms {
+		gen.initialisms = initialismSet{}
+	}
+	return gen, roots, nil
+}
 
-	if err := gen.do(out, bldr.buildXML()); err != nil {
-		fmt.Println("Code generation failed unexpectedly:", err.Error())
-		os.Exit(1)
+// checkTagKey checks a key of Options.FieldTags, which must be a valid
+// struct tag key other than those goxsd generates itself.
+func checkTagKey(key string) error {
+	if key == "xml" || key == "json" {
+		return fmt.Errorf("field tag %q is generated already", key)
+	}
+	if key == "" || strings.IndexFunc(key, func(r rune) bool {
+		return r <= ' ' || r == 0x7f || r == '"' || r == ':' || r == '`'
+	}) >= 0 {
+		return fmt.Errorf("field tag %q is not a valid struct tag key", key)
+	}
+	return nil
+}
+
+// typeMap returns the Go types of Options.TypeMap by the local name of
+// their XSD type, qualified by the name of their package, along with the
+// import paths of the packages by name.
+func typeMap(m map[string]string) (types, packages map[string]string, err error) {
+	types, packages = make(map[string]string), make(map[string]string)
+	for xsdType, goType := range m {
+		name := stripNamespace(xsdType)
+		if _, ok := builtinGoType(name); !ok {
+			return nil, nil, fmt.Errorf("type map: %s is not a built-in XSD type", xsdType)
+		}
+		typ, err := qualifiedType(goType, packages)
+		if err != nil {
+			return nil, nil, fmt.Errorf("type map: %v", err)
+		}
+		types[name] = typ
 	}
+	return types, packages, nil
+}
+
+// qualifiedType returns the name in Go source of a Go type that is
+// predeclared, or qualified by its import path, in which case the path is
+// added to packages, by package name.
+func qualifiedType(goType string, packages map[string]string) (string, error) {
+	i := strings.LastIndex(goType, ".")
+	if i < 0 {
+		if !builtinType(goType) {
+			return "", fmt.Errorf("%s is not a predeclared Go type, nor qualified by its import path", goType)
+		}
+		return goType, nil
+	}
+	path, typ := goType[:i], goType[i+1:]
+	pkg := packageName(path)
+	if !token.IsIdentifier(pkg) || !token.IsExported(typ) {
+		return "", fmt.Errorf("%s is not an exported Go type qualified by its import path", goType)
+	}
+	if p, ok := packages[pkg]; ok && p != path {
+		return "", fmt.Errorf("packages %s and %s have the same name", p, path)
+	}
+	packages[pkg] = path
+	return pkg + "." + typ, nil
+}
+
+// packageName returns the name of the package at an import path, assumed to
+// be its last element, or the element before a major version suffix such as
+// v2.
+func packageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// Check returns a report line for every construct of the XSD schema at
+// xsdPath, and the schemas it imports, that goxsd does not support.
+func Check(xsdPath string, opts Options) ([]string, error) {
+	schemas, err := parseXSDPath(xsdPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return check(schemas)
+}
+
+// CheckFrom is like Check, but reads the XSD schema from r.
+func CheckFrom(r io.Reader, opts Options) ([]string, error) {
+	schemas, err := parseXSDReader(r, opts.NoNetwork)
+	if err != nil {
+		return nil, err
+	}
+	return check(schemas)
 }
 
 type xmlTree struct {
+	Name      string
+	Namespace string // namespace of a top-level element
+	Root      bool   // the struct is only used for a top-level element
+	Type      string
+	TypeName  string // named XSD type, empty for inline types
+	List      bool
+	Optional  bool
+	Nillable  bool // may be nil by xsi:nil, even if required
+	Cdata     bool
+	InnerXML  bool // keeps the raw content of an element of anyType
+	AnyAttrs  bool // collects the attributes the schema does not declare
+
+	SimpleList bool // whitespace separated list of Type values
+	// minOccurs and maxOccurs of the element, with their defaults filled
+	// in, if recorded for Options.CardinalityComments
+	MinOccurs, MaxOccurs string
+	Wrapper              string // element wrapping a list of the element, flattened into its field
+	FieldName            string // Go name of the field of the element, if given by a directive
+	Choice               bool   // a branch of the interface field of the choice of its parent
+	ChoiceList           bool   // the interface field of its choice is a slice
+
+	Enums  []string   // enumeration facets of a simple type
+	Facets *xmlFacets // pattern and length facets of a simple type
+	// generated as a named string type checking the pattern facet
+	PatternType bool
+	Ref         bool   // refers to a struct generated for another element
+	Doc         string // documentation of the element
+	TypeDoc     string // documentation of the element's type
+	Source      string // XSD construct the type is generated from
+	Attribs     []xmlAttrib
+	Children    []*xmlTree
+}
+
+type xmlAttrib struct {
 	Name     string
 	Type     string
-	List     bool
-	Cdata    bool
-	Attribs  []xmlAttrib
-	Children []*xmlTree
+	Optional bool
+	Doc      string
+	Default  string
+	Fixed    string     // the only value allowed by the schema, if not empty
+	Enums    []string   // enumeration facets of the attribute type
+	Facets   *xmlFacets // pattern and length facets of the attribute type
 }
 
-type xmlAttrib struct {
-	Name string
-	Type string
+// xmlFacets are the facets of a simple type that constrain the lexical form
+// of its values.
+type xmlFacets struct {
+	// XSD regular expressions, any of which a value matches, empty
+	// without a pattern
+	Patterns  []string
+	MinLength int
+	MaxLength int // -1 without a maximum
+}
+
+func (f *xmlFacets) String() string {
+	if f == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%q %d-%d", f.Patterns, f.MinLength, f.MaxLength)
 }
 
+// builder builds the element trees of a set of schemas. Its maps are
+// registries for looking up definitions by name only; anything that affects
+// the order of the generated source iterates the schemas, or sorts, so that
+// the output is the same on every run.
 type builder struct {
-	schemas    []xsdSchema
-	complTypes map[string]xsdComplexType
-	simplTypes map[string]xsdSimpleType
+	schemas  []xsdSchema
+	elements map[qname]xsdElement
+	// qualified names of the top-level elements, by the local name they
+	// were first declared under
+	elementNames map[string]qname
+	// members of substitution groups, by head element
+	substitutes map[qname][]qname
+	complTypes  map[qname]xsdComplexType
+	simplTypes  map[qname]xsdSimpleType
+	// qualified names of the types, by the unique name they are
+	// generated under and by their local name
+	typeNames map[string]qname
+	// Go types overriding those of built-in types, by local name
+	typeMap map[string]string
+	// build string types restricted by a pattern as named types
+	patternTypes bool
+	// build the choices of complex types as the branches of an interface
+	choiceInterface bool
+	// record the minOccurs and maxOccurs of elements
+	cardinality bool
+	attrGroups  map[string]xsdAttributeGroup
+	groups      map[string]xsdGroup
+	// top-level attributes, by name
+	attributes map[string]xsdAttribute
+	// results of findType by type name, while building
+	resolved map[string]interface{}
+
+	// complex types currently being expanded, by type name, and top-level
+	// elements, as "element <name>"
+	expanding map[string]struct{}
+	// complex types built at least once
+	built map[string]struct{}
+	// element refs that name no top-level element, with the top-level
+	// definition they were first found in
+	undefined map[string]string
+	// attribute refs that name no top-level attribute, likewise
+	undefinedAttrs map[string]string
+	// errors of invalid appinfo directives, likewise
+	invalid map[string]string
+	// import paths of the packages of the Go types of directives, and of
+	// Options.TypeMap, by package name
+	packages map[string]string
+	// type names that match no definition or built-in type, with the
+	// constructs that refer to them, and the top-level definition of the
+	// first one
+	unresolved   map[string][]string
+	unresolvedAt map[string]string
+
+	// trees built from top-level elements, as roots or through refs
+	topLevel map[*xmlTree]struct{}
+
+	// the top-level definition being built, and the schema it is in, such
+	// as "element 'order' in schema 'orders.xsd'"
+	building *string
+	// receives the lines of Options.Log, if not nil
+	log io.Writer
 }
 
-func (b builder) buildXML() []*xmlTree {
+// newBuilder returns a builder for the given schemas, with empty registries
+// for the named definitions that are collected by buildXML.
+func newBuilder(schemas []xsdSchema) builder {
+	return builder{
+		schemas:     schemas,
+		elements:    make(map[qname]xsdElement),
+		substitutes: make(map[qname][]qname),
+		complTypes:  make(map[qname]xsdComplexType),
+		simplTypes:  make(map[qname]xsdSimpleType),
+		typeNames:   make(map[string]qname),
+		attrGroups:  make(map[string]xsdAttributeGroup),
+		groups:      make(map[string]xsdGroup),
+		attributes:  make(map[string]xsdAttribute),
+		expanding:   make(map[string]struct{}),
+		built:       make(map[string]struct{}),
+		undefined:   make(map[string]string),
+		unresolved:  make(map[string][]string),
+
+		elementNames:   make(map[string]qname),
+		unresolvedAt:   make(map[string]string),
+		undefinedAttrs: make(map[string]string),
+		invalid:        make(map[string]string),
+		packages:       make(map[string]string),
+		topLevel:       make(map[*xmlTree]struct{}),
+		building:       new(string),
+	}
+}
+
+func (b builder) buildXML() ([]*xmlTree, error) {
 	var roots []xsdElement
+	var locs []string
+	// A top-level element is built once per qualified name, however many
+	// schemas declare it, in the order it is first declared
+	qualified := make(map[string]struct{})
 	for _, s := range b.schemas {
 		for _, e := range s.Elements {
+			e.ns = s.TargetNs
+			if _, ok := qualified[e.ns+" "+e.Name]; ok {
+				continue
+			}
+			qualified[e.ns+" "+e.Name] = struct{}{}
 			roots = append(roots, e)
+			locs = append(locs, s.loc)
+			q := qname{e.ns, e.Name}
+			b.elements[q] = e
+			if _, ok := b.elementNames[e.Name]; !ok {
+				b.elementNames[e.Name] = q
+			}
 		}
 		for _, t := range s.ComplexTypes {
-			b.complTypes[t.Name] = t
+			b.addComplexType(s.TargetNs, t)
 		}
 		for _, t := range s.SimpleTypes {
-			b.simplTypes[t.Name] = t
+			b.addSimpleType(s.TargetNs, t)
+		}
+		for _, g := range s.AttributeGroups {
+			b.attrGroups[g.Name] = g
+		}
+		for _, g := range s.Groups {
+			b.groups[g.Name] = g
+		}
+		for _, a := range s.Attributes {
+			b.attributes[a.Name] = a
 		}
 	}
+	for _, s := range b.schemas {
+		for _, r := range s.Redefines {
+			b.redefine(s.TargetNs, r)
+		}
+	}
+	// The heads of substitution groups may be declared after their members
+	for _, e := range roots {
+		if head, ok := b.elementName(e.Substitutes); ok {
+			b.substitutes[head] = append(b.substitutes[head], qname{e.ns, e.Name})
+		}
+	}
+	// The definitions no longer change, so type names can be resolved
+	// once. The cache belongs to this build only.
+	b.resolved = make(map[string]interface{})
+
+	for i, e := range roots {
+		roots[i] = b.substituteType(e, make(map[qname]struct{}))
+		b.elements[qname{e.ns, e.Name}] = roots[i]
+	}
 
 	var xelems []*xmlTree
+	for i, e := range roots {
+		*b.building = fmt.Sprintf("element '%s' in schema '%s'", e.Name, schemaName(locs[i]))
+		x := b.buildFromTopLevel(e)
+		// The struct of a root of a simple type holds its value as
+		// chardata
+		if primitiveType(x) && !x.InnerXML {
+			x.Cdata = true
+		}
+		xelems = append(xelems, x)
+	}
+	markRoots(xelems)
+
+	// Named complex types not used by any element still get a type of
+	// their own, unless they are abstract, and only exist for other types
+	// to derive from
+	for _, s := range b.schemas {
+		for _, t := range s.ComplexTypes {
+			q := qname{s.TargetNs, t.Name}
+			t = b.complTypes[q]
+			if _, ok := b.built[t.Name]; !ok && !t.Abstract {
+				*b.building = fmt.Sprintf("complexType '%s' in schema '%s'", t.Name, schemaName(s.loc))
+				xelems = append(xelems, b.buildFromElement(xsdElement{Name: t.Name, Type: q.String()}))
+			}
+		}
+	}
+
+	if len(b.undefined) > 0 {
+		return nil, undefinedError("element", b.undefined)
+	}
+	if len(b.undefinedAttrs) > 0 {
+		return nil, undefinedError("attribute", b.undefinedAttrs)
+	}
+	if len(b.invalid) > 0 {
+		var errs []string
+		for err := range b.invalid {
+			errs = append(errs, err)
+		}
+		sort.Strings(errs)
+		return nil, fmt.Errorf("while building %s: %s", b.invalid[errs[0]], errs[0])
+	}
+
+	dedupe(xelems, b.topLevel)
+	return xelems, nil
+}
+
+// undefinedError returns the error about refs to undefined top-level
+// definitions of a kind, naming where the first one is.
+func undefinedError(kind string, undefined map[string]string) error {
+	var refs []string
+	for ref := range undefined {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return fmt.Errorf("while building %s: undefined %s ref: %s", undefined[refs[0]], kind, strings.Join(refs, ", "))
+}
+
+// qname is the qualified name of a definition.
+type qname struct {
+	ns, local string
+}
+
+// String returns the name in {namespace}local form, or the local name
+// alone without a namespace.
+func (q qname) String() string {
+	if q.ns == "" {
+		return q.local
+	}
+	return "{" + q.ns + "}" + q.local
+}
+
+// addComplexType registers a named complex type of a target namespace. A
+// type that has the local name of a type in another namespace is renamed
+// to a unique name, so that the structs generated for them do not clash.
+func (b builder) addComplexType(ns string, t xsdComplexType) {
+	q := qname{ns, t.Name}
+	if old, ok := b.complTypes[q]; ok {
+		t.Name = old.Name
+	} else {
+		t.Name = b.uniqueTypeName(q)
+	}
+	b.complTypes[q] = t
+}
+
+// addSimpleType is like addComplexType for simple types.
+func (b builder) addSimpleType(ns string, t xsdSimpleType) {
+	q := qname{ns, t.Name}
+	if old, ok := b.simplTypes[q]; ok {
+		t.Name = old.Name
+	} else {
+		t.Name = b.uniqueTypeName(q)
+	}
+	b.simplTypes[q] = t
+}
+
+// uniqueTypeName returns the local name of a type, numbered if it is
+// already taken by a type of another namespace. Only the first type of a
+// local name is found by its local name.
+func (b builder) uniqueTypeName(q qname) string {
+	if _, ok := b.typeNames[q.local]; !ok {
+		b.typeNames[q.local] = q
+		return q.local
+	}
+	for n := 2; ; n++ {
+		name := q.local + strconv.Itoa(n)
+		if _, ok := b.typeNames[name]; !ok {
+			b.typeNames[name] = q
+			return name
+		}
+	}
+}
+
+// redefine replaces the definitions of a redefined schema, of the target
+// namespace ns. The definitions they replace are kept under their original
+// name, which the redefinitions are changed to derive from or refer to
+// instead of their own name.
+func (b builder) redefine(ns string, r xsdRedefine) {
+	for _, t := range r.ComplexTypes {
+		if orig, ok := b.complTypes[qname{ns, t.Name}]; ok {
+			orig.Name = originalName(t.Name)
+			b.addComplexType(ns, orig)
+		}
+		if c := t.ComplexContent; c != nil {
+			t.ComplexContent = &xsdComplexContent{
+				Extension:   rebaseExtension(c.Extension, t.Name),
+				Restriction: rebaseRestriction(c.Restriction, t.Name),
+			}
+		}
+		if c := t.SimpleContent; c != nil {
+			t.SimpleContent = &xsdSimpleContent{
+				Extension:   rebaseExtension(c.Extension, t.Name),
+				Restriction: rebaseRestriction(c.Restriction, t.Name),
+			}
+		}
+		b.addComplexType(ns, t)
+	}
+
+	for _, t := range r.SimpleTypes {
+		if orig, ok := b.simplTypes[qname{ns, t.Name}]; ok {
+			orig.Name = originalName(t.Name)
+			b.addSimpleType(ns, orig)
+		}
+		t.Restriction = rebaseRestriction(t.Restriction, t.Name)
+		b.addSimpleType(ns, t)
+	}
+
+	for _, g := range r.Groups {
+		if orig, ok := b.groups[g.Name]; ok {
+			orig.Name = originalName(g.Name)
+			b.groups[orig.Name] = orig
+		}
+		refs := append([]xsdGroup(nil), g.SequenceGroups...)
+		for i := range refs {
+			if stripNamespace(refs[i].Ref) == g.Name {
+				refs[i].Ref = originalName(g.Name)
+			}
+		}
+		g.SequenceGroups = refs
+		b.groups[g.Name] = g
+	}
+
+	for _, g := range r.AttributeGroups {
+		if orig, ok := b.attrGroups[g.Name]; ok {
+			orig.Name = originalName(g.Name)
+			b.attrGroups[orig.Name] = orig
+		}
+		refs := append([]xsdAttributeGroup(nil), g.AttributeGroups...)
+		for i := range refs {
+			if stripNamespace(refs[i].Ref) == g.Name {
+				refs[i].Ref = originalName(g.Name)
+			}
+		}
+		g.AttributeGroups = refs
+		b.attrGroups[g.Name] = g
+	}
+}
+
+// originalName is the name a redefined definition is kept under. It cannot
+// clash with the name of any other definition.
+func originalName(name string) string {
+	return name + "#original"
+}
+
+// rebaseExtension returns a copy of the extension of a redefined type that
+// extends the original type, if the extension is of the type itself.
+func rebaseExtension(e *xsdExtension, name string) *xsdExtension {
+	if e == nil || stripNamespace(e.Base) != name {
+		return e
+	}
+	c := *e
+	c.Base = originalName(name)
+	return &c
+}
+
+// rebaseRestriction is like rebaseExtension for restrictions.
+func rebaseRestriction(r *xsdRestriction, name string) *xsdRestriction {
+	if r == nil || stripNamespace(r.Base) != name {
+		return r
+	}
+	c := *r
+	c.Base = originalName(name)
+	return &c
+}
+
+// markRoots marks the top-level elements whose struct is not used for any
+// other element, so that it can name the element it is marshalled to.
+func markRoots(roots []*xmlTree) {
+	uses := make(map[string]int)
+	var walk func(e *xmlTree)
+	walk = func(e *xmlTree) {
+		// Fields of built-in types use no struct
+		if !primitiveType(e) {
+			uses[structName(e)]++
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
 	for _, e := range roots {
-		xelems = append(xelems, b.buildFromElement(e))
+		walk(e)
 	}
 
-	return xelems
+	for _, e := range roots {
+		if uses[structName(e)] == 1 && !primitiveType(e) && !enumType(e) && !patternType(e) {
+			e.Root = true
+		}
+	}
+}
+
+// flattenWrappers replaces the children that only wrap a list of another
+// element, with neither attributes nor other content, by that list, which
+// keeps the name of its wrapper.
+func flattenWrappers(roots []*xmlTree) {
+	seen := make(map[*xmlTree]struct{})
+	var walk func(e *xmlTree)
+	walk = func(e *xmlTree) {
+		if _, ok := seen[e]; ok {
+			return
+		}
+		seen[e] = struct{}{}
+		for i, c := range e.Children {
+			if l := wrappedList(c); l != nil {
+				flat := *l
+				flat.Wrapper = c.Name
+				flat.Optional = c.Optional || l.Optional
+				flat.Doc = joinDoc(c.Doc, l.Doc)
+				if c.FieldName != "" {
+					flat.FieldName = c.FieldName
+				}
+				e.Children[i] = &flat
+				c = &flat
+			}
+			walk(c)
+		}
+	}
+	for _, e := range roots {
+		walk(e)
+	}
+}
+
+// wrappedList returns the only child of a wrapper element, which is a list,
+// or nil if e is not a wrapper.
+func wrappedList(e *xmlTree) *xmlTree {
+	if e.List || e.Ref || e.Nillable || e.Cdata || e.InnerXML || e.AnyAttrs || e.Wrapper != "" || e.Choice ||
+		len(e.Attribs) > 0 || len(e.Children) != 1 {
+		return nil
+	}
+	if l := e.Children[0]; l.List && l.Wrapper == "" && !l.Choice {
+		return l
+	}
+	return nil
+}
+
+// dedupe gives inline types that share an element name, but not their
+// structure, a type each. Inline types are generated once per element name,
+// so without it every such element would get the struct of the first one.
+// Identical structures keep sharing a single type, and so do all uses of a
+// top-level element.
+func dedupe(roots []*xmlTree, topLevel map[*xmlTree]struct{}) {
+	keys := make(map[*xmlTree]string)
+	for _, e := range roots {
+		structKey(e, topLevel, keys)
+	}
+
+	// The inline types of top-level elements keep the name of their
+	// element, which the refs of recursive elements refer to
+	shapes := make(map[string][]string)
+	for _, e := range roots {
+		if _, ok := topLevel[e]; ok && e.TypeName == "" {
+			shapes[e.Name] = appendKey(shapes[e.Name], keys[e])
+		}
+	}
+	seen := make(map[string]struct{})
+	var walk func(e *xmlTree)
+	walk = func(e *xmlTree) {
+		if e.TypeName == "" {
+			shapes[e.Name] = appendKey(shapes[e.Name], keys[e])
+			if n := indexOf(shapes[e.Name], keys[e]); n > 0 {
+				e.TypeName = e.Name + strconv.Itoa(n+1)
+				if e.TypeDoc == "" {
+					e.TypeDoc = e.Doc
+				}
+				// The types of character data, patterns and enumerations
+				// keep their base type
+				if !e.Cdata && !patternType(e) && !enumType(e) {
+					e.Type = e.TypeName
+				}
+			}
+		}
+		if _, ok := seen[structName(e)]; ok {
+			return
+		}
+		seen[structName(e)] = struct{}{}
+		for _, c := range e.Children {
+			if generatedType(c) {
+				walk(c)
+			}
+		}
+	}
+	for _, e := range roots {
+		walk(e)
+	}
+}
+
+// structKey returns a key that is equal for elements whose generated types
+// are identical, recording the key of every inline element in keys. Named
+// types are identified by their name, and so are top-level elements.
+func structKey(e *xmlTree, topLevel map[*xmlTree]struct{}, keys map[*xmlTree]string) string {
+	if e.TypeName != "" {
+		if !e.Ref {
+			for _, c := range e.Children {
+				structKey(c, topLevel, keys)
+			}
+		}
+		return "type " + e.TypeName
+	}
+
+	var key bytes.Buffer
+	fmt.Fprintf(&key, "%s %s %s %t %t %t %t %t %q %s{", e.Namespace, e.Name, e.Type, e.Cdata, e.InnerXML, e.AnyAttrs, e.SimpleList, e.ChoiceList, e.Enums, e.Facets)
+	for _, a := range e.Attribs {
+		fmt.Fprintf(&key, "%s %s %t %q %q %s;", a.Name, a.Type, a.Optional, a.Default, a.Fixed, a.Facets)
+	}
+	for _, c := range e.Children {
+		fmt.Fprintf(&key, "%t %t %t %t %t %s %s %s %s;", c.List, c.Optional, c.Nillable, c.Ref, c.Choice,
+			c.MinOccurs, c.MaxOccurs, c.FieldName, structKey(c, topLevel, keys))
+	}
+	key.WriteString("}")
+
+	// A top-level element has a single type, wherever it is used. Where
+	// it is built through a ref within its own expansion, its recursive
+	// refs may differ from those of its other uses.
+	keys[e] = key.String()
+	if _, ok := topLevel[e]; ok {
+		keys[e] = fmt.Sprintf("element %s %s", e.Namespace, e.Name)
+	}
+	return keys[e]
+}
+
+func appendKey(keys []string, key string) []string {
+	if indexOf(keys, key) < 0 {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func indexOf(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
 }
 
 // buildFromElement builds an xmlElem from an xsdElement, recursively
 // traversing the XSD type information to build up an XML element hierarchy.
 func (b builder) buildFromElement(e xsdElement) *xmlTree {
-	xelem := &xmlTree{Name: e.Name, Type: e.Name}
+	if e.Ref != "" {
+		return b.buildFromRef(e)
+	}
+
+	xelem := &xmlTree{Name: e.Name, Namespace: e.ns, Doc: e.Annotation}
 
 	if e.isList() {
 		xelem.List = true
 	}
 
+	if e.isOptional() {
+		xelem.Optional = true
+	}
+	xelem.Nillable = e.Nillable
+	b.occurs(xelem, e)
+
+	// A Go type given by a directive replaces the type of the element,
+	// which is not built
+	d := b.directives(e)
+	xelem.FieldName = d.Name
+	if d.Type != "" {
+		xelem.Type = d.Type
+		return xelem
+	}
+
 	if !e.inlineType() {
 		switch t := b.findType(e.Type).(type) {
 		case xsdComplexType:
+			// A type that is already being expanded further up the tree is
+			// referenced by the struct generated there, instead of recursing
+			// forever.
+			xelem.TypeName = t.Name
+			xelem.TypeDoc = t.Annotation
+			xelem.Type = t.Name
+			xelem.Source = fmt.Sprintf("complexType '%s'", t.Name)
+			if t.Abstract {
+				xelem.Doc = joinDoc(xelem.Doc, b.abstractNote(t.Name))
+			}
+			if _, ok := b.expanding[t.Name]; ok {
+				b.logf("element '%s' refers to complexType '%s', which is being built, by pointer", e.Name, t.Name)
+				xelem.Ref = true
+				return xelem
+			}
+			b.logf("building complexType '%s' of element '%s'", t.Name, e.Name)
+			b.expanding[t.Name] = struct{}{}
 			b.buildFromComplexType(xelem, t)
+			delete(b.expanding, t.Name)
+			b.built[t.Name] = struct{}{}
 		case xsdSimpleType:
+			xelem.Source = fmt.Sprintf("simpleType '%s'", t.Name)
 			b.buildFromSimpleType(xelem, t)
+			// An enumeration type is generated once under the name of its
+			// simple type, however many elements are of it
+			if enumType(xelem) {
+				xelem.TypeName = t.Name
+			}
 		case string:
-			xelem.Type = t
+			if t == "anyType" {
+				buildFromAnyType(xelem)
+				break
+			}
+			xelem.Type = b.goType(t, fmt.Sprintf("element '%s'", e.Name))
+			xelem.Doc = joinDoc(xelem.Doc, binaryNote(e.Type))
 		}
 		return xelem
 	}
 
 	if e.ComplexType != nil { // inline complex type
+		b.logf("building the complexType of element '%s'", e.Name)
+		xelem.Source = fmt.Sprintf("the complexType of element '%s'", e.Name)
+		xelem.TypeDoc = e.ComplexType.Annotation
 		b.buildFromComplexType(xelem, *e.ComplexType)
 		return xelem
 	}
 
 	if e.SimpleType != nil { // inline simple type
+		xelem.Source = fmt.Sprintf("the simpleType of element '%s'", e.Name)
 		b.buildFromSimpleType(xelem, *e.SimpleType)
 		return xelem
 	}
 
+	// An element without any type is of anyType
+	buildFromAnyType(xelem)
 	return xelem
 }
 
+// buildFromAnyType makes xelem hold any content and attributes, which are
+// kept as they are.
+func buildFromAnyType(xelem *xmlTree) {
+	xelem.Source = "anyType"
+	xelem.InnerXML = true
+	xelem.AnyAttrs = true
+}
+
+// substituteType gives a member of a substitution group that declares no
+// type of its own the type of its head element.
+func (b builder) substituteType(e xsdElement, seen map[qname]struct{}) xsdElement {
+	if e.Substitutes == "" || !e.inlineType() || e.ComplexType != nil || e.SimpleType != nil {
+		return e
+	}
+	q, ok := b.elementName(e.Substitutes)
+	if _, cycle := seen[q]; !ok || cycle {
+		return e
+	}
+	head := b.elements[q]
+	seen[qname{e.ns, e.Name}] = struct{}{}
+	head = b.substituteType(head, seen)
+	e.Type, e.ComplexType, e.SimpleType = head.Type, head.ComplexType, head.SimpleType
+	return e
+}
+
+// buildChildren builds the children that an element of a content model
+// stands for. A ref to the head of a substitution group stands for the head,
+// unless it is abstract, and for every member of the group, any of which
+// may appear in its place.
+func (b builder) buildChildren(e xsdElement) []*xmlTree {
+	if e.Ref == "" {
+		return []*xmlTree{b.buildFromElement(e)}
+	}
+	return b.substitutionGroup(e, make(map[qname]struct{}))
+}
+
+func (b builder) substitutionGroup(e xsdElement, seen map[qname]struct{}) []*xmlTree {
+	q, _ := b.elementName(e.Ref)
+	members := b.substitutes[q]
+	if _, ok := seen[q]; e.Ref == "" || len(members) == 0 || ok {
+		return []*xmlTree{b.buildFromElement(e)}
+	}
+	seen[q] = struct{}{}
+
+	var cs []*xmlTree
+	if !b.elements[q].Abstract {
+		cs = append(cs, b.buildFromElement(e))
+	}
+	for _, m := range members {
+		r := e
+		r.Ref = m.String()
+		cs = append(cs, b.substitutionGroup(r, seen)...)
+	}
+	for _, c := range cs {
+		c.Optional = true
+	}
+	return cs
+}
+
+// elementName resolves a reference to a top-level element to its qualified
+// name. A reference without a known namespace, or to a namespace without
+// such an element, is resolved by local name, like a type name.
+func (b builder) elementName(ref string) (qname, bool) {
+	ns, name := splitQName(ref)
+	if ref == "" {
+		return qname{}, false
+	}
+	if _, ok := b.elements[qname{ns, name}]; ok {
+		return qname{ns, name}, true
+	}
+	q, ok := b.elementNames[name]
+	if !ok {
+		return qname{ns, name}, false
+	}
+	return q, true
+}
+
+// buildFromRef builds an element that refers to a top-level element. The
+// referring element decides how often the element occurs, the top-level one
+// what it contains.
+func (b builder) buildFromRef(e xsdElement) *xmlTree {
+	q, ok := b.elementName(e.Ref)
+	name := q.local
+	ref := b.elements[q]
+	if !ok {
+		if _, ok := b.undefined[e.Ref]; !ok {
+			b.undefined[e.Ref] = *b.building
+		}
+		b.logf("undefined element ref '%s'", e.Ref)
+		return &xmlTree{Name: name, Type: "string"}
+	}
+	ref.Min, ref.Max = e.Min, e.Max
+	if e.Annotation != "" {
+		ref.Annotation = e.Annotation
+	}
+
+	// An element with an inline type that contains itself refers to the
+	// struct generated for it further up the tree.
+	if _, ok := b.expanding["element "+q.String()]; ok && ref.inlineType() {
+		b.logf("ref to element '%s', which is being built, by pointer", name)
+		xelem := &xmlTree{
+			Name:      name,
+			Namespace: ref.ns,
+			List:      ref.isList(),
+			Optional:  ref.isOptional(),
+			Ref:       true,
+			Doc:       ref.Annotation,
+		}
+		b.occurs(xelem, ref)
+		return xelem
+	}
+	return b.buildFromTopLevel(ref)
+}
+
+// occurs records the minOccurs and maxOccurs of an element, if they are
+// commented.
+func (b builder) occurs(xelem *xmlTree, e xsdElement) {
+	if !b.cardinality {
+		return
+	}
+	xelem.MinOccurs, xelem.MaxOccurs = e.Min, e.Max
+	if xelem.MinOccurs == "" {
+		xelem.MinOccurs = "1"
+	}
+	if xelem.MaxOccurs == "" {
+		xelem.MaxOccurs = "1"
+	}
+}
+
+// buildFromTopLevel builds a top-level element, either as a root or through
+// a ref.
+func (b builder) buildFromTopLevel(e xsdElement) *xmlTree {
+	key := "element " + qname{e.ns, e.Name}.String()
+	b.expanding[key] = struct{}{}
+	defer delete(b.expanding, key)
+	x := b.buildFromElement(e)
+	b.topLevel[x] = struct{}{}
+	return x
+}
+
 // buildFromComplexType takes an xmlElem and an xsdComplexType, containing
 // XSD type information for xmlElem enrichment.
 func (b builder) buildFromComplexType(xelem *xmlTree, t xsdComplexType) {
-	if t.Sequence != nil { // Does the element have children?
-		for _, e := range t.Sequence {
-			xelem.Children = append(xelem.Children, b.buildFromElement(e))
-		}
+	// Text interspersed with the children of mixed content is collected as
+	// chardata
+	if t.Mixed {
+		xelem.Cdata = true
+		xelem.Type = "string"
+	}
+
+	b.buildFromSequence(xelem, t.Sequence, t.SequenceChoice, t.SequenceGroups, nil)
+	b.buildFromTypeChoice(xelem, t.Choice)
+
+	for _, e := range t.All {
+		xelem.Children = append(xelem.Children, b.buildChildren(e)...)
 	}
 
-	if t.Attributes != nil {
-		b.buildFromAttributes(xelem, t.Attributes)
+	if t.AnyAttribute != nil {
+		xelem.AnyAttrs = true
+	}
+	if attrs := b.expandAttributes(t.Attributes, t.AttributeGroups); attrs != nil {
+		b.buildFromAttributes(xelem, attrs)
 	}
 
 	if t.ComplexContent != nil {
@@ -171,16 +1244,278 @@ func (b builder) buildFromComplexType(xelem *xmlTree, t xsdComplexType) {
 	}
 }
 
-// buildFromSimpleType assumes restriction child and fetches the base value,
-// assuming that value is of a XSD built-in data type.
+// buildFromSimpleType fetches the base value of a restriction, assuming that
+// value is of a XSD built-in data type.
 func (b builder) buildFromSimpleType(xelem *xmlTree, t xsdSimpleType) {
-	xelem.Type = b.findType(t.Restriction.Base).(string)
+	if t.List != nil {
+		// encoding/xml does not split the list values, which the named
+		// slice type generated for the list does
+		xelem.SimpleList = true
+		xelem.Type = b.listItemGoType(*t.List)
+		return
+	}
+
+	xelem.Type = b.simpleGoType(t)
+	xelem.Enums = enumValues(t)
+	xelem.Facets = b.simpleFacets(t)
+	if b.patternTypes && len(xelem.Enums) == 0 && xelem.Type == "string" && xelem.Facets != nil && len(xelem.Facets.Patterns) > 0 {
+		xelem.PatternType = true
+	}
+	if (len(xelem.Enums) > 0 && constType(xelem.Type) || xelem.PatternType) && xelem.TypeDoc == "" {
+		xelem.TypeDoc = t.Annotation
+	}
+}
+
+// enumValues returns the enumeration facets of a simple type.
+func enumValues(t xsdSimpleType) []string {
+	if t.Restriction == nil {
+		return nil
+	}
+	var values []string
+	for _, e := range t.Restriction.Enumeration {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// simpleGoType returns the Go type of a simple type, following the bases of
+// restrictions down to a built-in type. A union has no single Go
+// representation, so it falls back to string, as does a simple type without
+// restriction and any base that is not a built-in type.
+func (b builder) simpleGoType(t xsdSimpleType) string {
+	seen := make(map[string]struct{})
+	for t.Union == nil && t.Restriction != nil {
+		if _, ok := seen[t.Name]; ok {
+			break
+		}
+		seen[t.Name] = struct{}{}
+
+		switch base := b.findType(t.Restriction.Base).(type) {
+		case xsdSimpleType:
+			t = base
+			continue
+		case string:
+			return b.goType(base, fmt.Sprintf("simpleType '%s'", t.Name))
+		}
+		break
+	}
+	return "string"
+}
+
+// simpleFacets returns the pattern and length facets of a simple type,
+// including those it inherits through its restriction bases, or nil if it
+// has none. A facet of a derived type overrides the same facet of its base.
+func (b builder) simpleFacets(t xsdSimpleType) *xmlFacets {
+	f := xmlFacets{MinLength: -1, MaxLength: -1}
+	found := false
+	seen := make(map[string]struct{})
+	for t.Restriction != nil {
+		if _, ok := seen[t.Name]; ok {
+			break
+		}
+		seen[t.Name] = struct{}{}
+
+		// The patterns of a restriction are alternatives, whereas a
+		// derived type has its own patterns only
+		r := t.Restriction
+		if len(f.Patterns) == 0 {
+			for _, p := range r.Patterns {
+				if p.Value != "" {
+					f.Patterns = append(f.Patterns, p.Value)
+					found = true
+				}
+			}
+		}
+		min, max := r.MinLength, r.MaxLength
+		if r.Length != nil {
+			min, max = r.Length, r.Length
+		}
+		if n, err := strconv.Atoi(facetValue(min)); f.MinLength < 0 && err == nil {
+			f.MinLength = n
+			found = true
+		}
+		if n, err := strconv.Atoi(facetValue(max)); f.MaxLength < 0 && err == nil {
+			f.MaxLength = n
+			found = true
+		}
+
+		base, ok := b.findType(r.Base).(xsdSimpleType)
+		if !ok {
+			break
+		}
+		t = base
+	}
+	if !found {
+		return nil
+	}
+	if f.MinLength < 0 {
+		f.MinLength = 0
+	}
+	return &f
+}
+
+func facetValue(f *xsdFacet) string {
+	if f == nil {
+		return ""
+	}
+	return f.Value
+}
+
+// listItemGoType returns the Go type of the items of a list simple type.
+func (b builder) listItemGoType(l xsdList) string {
+	if l.SimpleType != nil {
+		return b.simpleGoType(*l.SimpleType)
+	}
+	switch t := b.findType(l.ItemType).(type) {
+	case xsdSimpleType:
+		return b.simpleGoType(t)
+	case string:
+		return b.goType(t, "a list")
+	}
+	return "string"
+}
+
+// buildFromSequence appends the children of a sequence in document order:
+// those of its elements, of the elements of its choices, which are
+// optional, and of the groups it refers to, expanded in place. The groups
+// already in seen are skipped, or none if seen is nil.
+func (b builder) buildFromSequence(xelem *xmlTree, elems, choice []xsdElement, groups []xsdGroup, seen map[string]struct{}) {
+	type particle struct {
+		pos   int64
+		build func()
+	}
+	ps := make([]particle, 0, len(elems)+len(groups)+len(choice))
+	if n := len(elems) + len(choice); xelem.Children == nil && n > 0 {
+		xelem.Children = make([]*xmlTree, 0, n)
+	}
+	for _, e := range elems {
+		e := e
+		ps = append(ps, particle{e.pos, func() {
+			xelem.Children = append(xelem.Children, b.buildChildren(e)...)
+		}})
+	}
+	for _, g := range groups {
+		ref := g.Ref
+		ps = append(ps, particle{g.pos, func() {
+			s := seen
+			if s == nil {
+				s = make(map[string]struct{})
+			}
+			b.buildFromGroup(xelem, ref, s)
+		}})
+	}
+	for _, e := range choice {
+		e := e
+		ps = append(ps, particle{e.pos, func() {
+			b.buildFromChoice(xelem, []xsdElement{e})
+		}})
+	}
+
+	// Particles of schemas that were not parsed have no offsets, and
+	// keep the order above
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].pos < ps[j].pos })
+	for _, p := range ps {
+		p.build()
+	}
+}
+
+// buildFromChoice appends the branches of a choice as children of xelem.
+// Since only one branch is present at a time, every branch is optional.
+// Choices nested in a sequence are flattened into the parent's children.
+func (b builder) buildFromChoice(xelem *xmlTree, choice []xsdElement) {
+	for _, e := range choice {
+		for _, c := range b.buildChildren(e) {
+			c.Optional = true
+			xelem.Children = append(xelem.Children, c)
+		}
+	}
+}
+
+// buildFromTypeChoice builds the choice that is the content of a complex
+// type or of its extension. With Options.Choice interface, its elements
+// are the branches of an interface field instead of optional fields. A
+// choice that may occur more than once makes them lists, or the interface
+// field a slice.
+func (b builder) buildFromTypeChoice(xelem *xmlTree, choice xsdChoice) {
+	n := len(xelem.Children)
+	b.buildFromChoice(xelem, choice.Elements)
+	many := occursMany(choice.Max) && len(xelem.Children) > n
+	for _, c := range xelem.Children[n:] {
+		if b.choiceInterface {
+			c.Choice = true
+		} else if many {
+			c.List = true
+		}
+	}
+	if b.choiceInterface && many {
+		xelem.ChoiceList = true
+	}
+}
+
+// buildFromGroup resolves a reference to a named model group and appends
+// the elements it contains as children of xelem. Groups already in seen are
+// skipped, which guards against reference cycles.
+func (b builder) buildFromGroup(xelem *xmlTree, ref string, seen map[string]struct{}) {
+	name := stripNamespace(ref)
+	if _, ok := seen[name]; ok {
+		return
+	}
+	seen[name] = struct{}{}
+
+	g, ok := b.groups[name]
+	if !ok {
+		return
+	}
+
+	b.buildFromSequence(xelem, g.Sequence, nil, g.SequenceGroups, seen)
+	b.buildFromChoice(xelem, g.Choice)
+
+	for _, e := range g.All {
+		xelem.Children = append(xelem.Children, b.buildChildren(e)...)
+	}
 }
 
 func (b builder) buildFromComplexContent(xelem *xmlTree, c xsdComplexContent) {
 	if c.Extension != nil {
 		b.buildFromExtension(xelem, c.Extension)
 	}
+	if c.Restriction != nil {
+		b.buildFromComplexRestriction(xelem, c.Restriction)
+	}
+}
+
+// buildFromComplexRestriction restricts a complex type. The children are
+// those of the restriction alone, as it repeats the part of the content
+// model of its base that it keeps. Attributes of the base are inherited,
+// unless the restriction redeclares or prohibits them.
+func (b builder) buildFromComplexRestriction(xelem *xmlTree, r *xsdRestriction) {
+	var inherited []xmlAttrib
+	if t, ok := b.findType(r.Base).(xsdComplexType); ok {
+		base := &xmlTree{Name: xelem.Name}
+		b.buildFromComplexType(base, t)
+		inherited = base.Attribs
+	}
+
+	b.buildFromSequence(xelem, r.Sequence, r.SequenceChoice, r.SequenceGroups, nil)
+	b.buildFromTypeChoice(xelem, r.Choice)
+	for _, e := range r.All {
+		xelem.Children = append(xelem.Children, b.buildChildren(e)...)
+	}
+
+	if r.AnyAttribute != nil {
+		xelem.AnyAttrs = true
+	}
+	attrs := b.expandAttributes(r.Attributes, r.AttributeGroups)
+	restricted := make(map[string]struct{})
+	for _, a := range attrs {
+		restricted[a.Name] = struct{}{}
+	}
+	for _, a := range inherited {
+		if _, ok := restricted[a.Name]; !ok {
+			xelem.Attribs = append(xelem.Attribs, a)
+		}
+	}
+	b.buildFromAttributes(xelem, attrs)
 }
 
 // A simple content can refer to a text-only complex type
@@ -192,11 +1527,20 @@ func (b builder) buildFromSimpleContent(xelem *xmlTree, c xsdSimpleContent) {
 	if c.Restriction != nil {
 		b.buildFromRestriction(xelem, c.Restriction)
 	}
+
+	// The content is a simple value, so with any attributes it is collected
+	// as chardata, even when the attributes only come from a derived type
+	// whose base has none.
+	if len(xelem.Attribs) > 0 || xelem.AnyAttrs {
+		xelem.Cdata = true
+	}
 }
 
 // buildFromExtension extends an existing type, simple or complex, with a
 // sequence.
 func (b builder) buildFromExtension(xelem *xmlTree, e *xsdExtension) {
+	attrs := b.expandAttributes(e.Attributes, e.AttributeGroups)
+
 	switch t := b.findType(e.Base).(type) {
 	case xsdComplexType:
 		b.buildFromComplexType(xelem, t)
@@ -204,94 +1548,465 @@ func (b builder) buildFromExtension(xelem *xmlTree, e *xsdExtension) {
 		b.buildFromSimpleType(xelem, t)
 		// If element is of simpleType and has attributes, it must collect
 		// its value as chardata.
-		if e.Attributes != nil {
+		if attrs != nil {
 			xelem.Cdata = true
 		}
 	default:
-		xelem.Type = t.(string)
+		// Deriving from anyType is how a complex type is declared
+		// explicitly, and adds nothing to it
+		if t == "anyType" {
+			break
+		}
+		// A missing base is warned about, and taken to be a string
+		xelem.Type = b.goType(t.(string), fmt.Sprintf("the extension of element '%s'", xelem.Name))
 		// If element is of built-in type but has attributes, it must collect
 		// its value as chardata.
-		if e.Attributes != nil {
+		if attrs != nil {
 			xelem.Cdata = true
 		}
 	}
 
-	if e.Sequence != nil {
-		for _, e := range e.Sequence {
-			xelem.Children = append(xelem.Children, b.buildFromElement(e))
-		}
+	if e.AnyAttribute != nil {
+		xelem.AnyAttrs = true
+	}
+
+	b.buildFromSequence(xelem, e.Sequence, e.SequenceChoice, e.SequenceGroups, nil)
+	b.buildFromTypeChoice(xelem, e.Choice)
+
+	for _, e := range e.All {
+		xelem.Children = append(xelem.Children, b.buildChildren(e)...)
 	}
 
-	if e.Attributes != nil {
-		b.buildFromAttributes(xelem, e.Attributes)
+	if attrs != nil {
+		b.buildFromAttributes(xelem, attrs)
 	}
 }
 
+// buildFromRestriction restricts the type of a simple content. The
+// attributes of a complex base are inherited, unless the restriction
+// prohibits them, and those that it declares are added or replace them.
 func (b builder) buildFromRestriction(xelem *xmlTree, r *xsdRestriction) {
 	switch t := b.findType(r.Base).(type) {
 	case xsdSimpleType:
 		b.buildFromSimpleType(xelem, t)
 	case xsdComplexType:
 		b.buildFromComplexType(xelem, t)
-	case xsdComplexContent:
-		panic("Restriction on complex content is not implemented")
-	default:
-		panic("Unexpected base type to restriction")
+	case string:
+		// A built-in base, or a missing one, which is warned about and
+		// taken to be a string
+		xelem.Type = b.goType(t, fmt.Sprintf("the restriction of element '%s'", xelem.Name))
+	}
+
+	if r.AnyAttribute != nil {
+		xelem.AnyAttrs = true
 	}
+	attrs := b.expandAttributes(r.Attributes, r.AttributeGroups)
+	for i, a := range attrs {
+		if a.Ref != "" {
+			a = b.attributeRef(a)
+			attrs[i] = a
+		}
+		if j := attribIndex(xelem.Attribs, a.Name); j >= 0 && a.Use == "prohibited" {
+			xelem.Attribs = append(xelem.Attribs[:j], xelem.Attribs[j+1:]...)
+		}
+	}
+	b.buildFromAttributes(xelem, attrs)
 }
 
 func (b builder) buildFromAttributes(xelem *xmlTree, attrs []xsdAttribute) {
+	if xelem.Attribs == nil && len(attrs) > 0 {
+		xelem.Attribs = make([]xmlAttrib, 0, len(attrs))
+	}
 	for _, a := range attrs {
-		attr := xmlAttrib{Name: a.Name}
+		if a.Ref != "" {
+			a = b.attributeRef(a)
+		}
+		if a.Use == "prohibited" {
+			continue
+		}
+		attr := xmlAttrib{
+			Name:     a.Name,
+			Optional: a.isOptional(),
+			Doc:      a.Annotation,
+			Default:  a.Default,
+			Fixed:    a.Fixed,
+		}
 		switch t := b.findType(a.Type).(type) {
 		case xsdSimpleType:
 			// Get type name from simpleType
-			// If Restriction.Base is a simpleType or complexType, we panic
-			attr.Type = b.findType(t.Restriction.Base).(string)
+			attr.Type = b.simpleGoType(t)
+			attr.Enums = enumValues(t)
+			attr.Facets = b.simpleFacets(t)
 		case string:
 			// If empty, then simpleType is present as content, but we ignore
-			// that now
-			attr.Type = t
+			// that now, and take the attribute to be a string
+			attr.Type = "string"
+			if t != "" {
+				attr.Type = b.goType(t, fmt.Sprintf("attribute '%s'", a.Name))
+			}
+			attr.Doc = joinDoc(attr.Doc, binaryNote(a.Type))
+		}
+		// An attribute declared more than once, such as inline and by an
+		// attribute group, or by a base type and its extension, is one
+		// field, of the declaration that comes last
+		if i := attribIndex(xelem.Attribs, attr.Name); i >= 0 {
+			xelem.Attribs[i] = attr
+			continue
 		}
 		xelem.Attribs = append(xelem.Attribs, attr)
 	}
 }
 
+// attribIndex returns the index of the attribute with the given name, or -1
+// if there is none.
+func attribIndex(attrs []xmlAttrib, name string) int {
+	for i, a := range attrs {
+		if a.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// expandAttributes returns the attributes declared inline, followed by the
+// attributes of all referenced attribute groups.
+func (b builder) expandAttributes(attrs []xsdAttribute, groups []xsdAttributeGroup) []xsdAttribute {
+	seen := make(map[string]struct{})
+	for _, g := range groups {
+		attrs = append(attrs, b.attributeGroup(g.Ref, seen)...)
+	}
+	return attrs
+}
+
+// attributeGroup resolves a reference to a named attribute group, including
+// the groups it references in turn. Groups already in seen are skipped,
+// which guards against reference cycles.
+func (b builder) attributeGroup(ref string, seen map[string]struct{}) []xsdAttribute {
+	name := stripNamespace(ref)
+	if _, ok := seen[name]; ok {
+		return nil
+	}
+	seen[name] = struct{}{}
+
+	g, ok := b.attrGroups[name]
+	if !ok {
+		return nil
+	}
+	attrs := append([]xsdAttribute(nil), g.Attributes...)
+	for _, r := range g.AttributeGroups {
+		attrs = append(attrs, b.attributeGroup(r.Ref, seen)...)
+	}
+	return attrs
+}
+
+// xmlNamespace is the namespace bound to the xml prefix, whose attributes,
+// such as xml:lang, are predefined.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// attributeRef resolves a reference to a top-level attribute. The referring
+// attribute decides whether it is required, and may give it a default or
+// fixed value, the top-level one its name and type. Attributes of the xml
+// namespace, such as xml:lang, need no declaration. Undefined refs are
+// recorded, so that they can be reported, and taken to be strings.
+func (b builder) attributeRef(ref xsdAttribute) xsdAttribute {
+	ns, name := splitQName(ref.Ref)
+	a, ok := b.attributes[name]
+	if !ok {
+		if ns != xmlNamespace && !strings.HasPrefix(ref.Ref, "xml:") {
+			if _, ok := b.undefinedAttrs[ref.Ref]; !ok {
+				b.undefinedAttrs[ref.Ref] = *b.building
+			}
+			b.logf("undefined attribute ref '%s'", ref.Ref)
+		}
+		a = xsdAttribute{Name: name, Type: "string"}
+	}
+	a.Ref, a.Use = "", ref.Use
+	if ref.Default != "" || ref.Fixed != "" {
+		a.Default, a.Fixed = ref.Default, ref.Fixed
+	}
+	if ref.Annotation != "" {
+		a.Annotation = ref.Annotation
+	}
+	return a
+}
+
+// goType returns the Go type of a built-in XSD type, as returned by findType.
+// Any other name refers to no definition, and is recorded as unresolved
+// along with the construct referring to it, so that it can be reported.
+// Its values are generated as strings, so that the output still compiles.
+func (b builder) goType(t, context string) string {
+	if builtinType(t) {
+		return t
+	}
+	if _, ok := b.unresolved[t]; !ok {
+		b.unresolvedAt[t] = *b.building
+	}
+	b.unresolved[t] = appendKey(b.unresolved[t], context)
+	b.logf("unresolved type '%s' of %s, using string", t, context)
+	return "string"
+}
+
+// unresolvedError returns the error of Options.Strict about the unresolved
+// type references, naming the first unresolved type and where it is.
+func (b builder) unresolvedError(refs int) error {
+	var names []string
+	for name := range b.unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	name := names[0]
+	what := fmt.Sprintf("unresolved type '%s'", name)
+	if name == "" {
+		what = "missing base type"
+	}
+	return fmt.Errorf("while building %s: %s of %s (1 of %d unresolved type references)",
+		b.unresolvedAt[name], what, b.unresolved[name][0], refs)
+}
+
+// schemaName names the schema at a location in error messages, by its file
+// name, or by its URL.
+func schemaName(loc string) string {
+	if isURL(loc) || loc == stdinLocation {
+		return loc
+	}
+	return filepath.Base(loc)
+}
+
+// unresolvedTypes returns a warning for every unresolved type name, in
+// order, and the number of references to them.
+func (b builder) unresolvedTypes() ([]string, int) {
+	var names []string
+	for name := range b.unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	refs := 0
+	for _, name := range names {
+		refs += len(b.unresolved[name])
+		if name == "" {
+			warnings = append(warnings, fmt.Sprintf("missing base type of %s, using string",
+				strings.Join(b.unresolved[name], ", ")))
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("unresolved type '%s' of %s, using string",
+			name, strings.Join(b.unresolved[name], ", ")))
+	}
+	return warnings, refs
+}
+
 // findType takes a type name and checks if it is a registered XSD type
 // (simple or complex), in which case that type is returned. If no such
 // type can be found, the XSD specific primitive types are mapped to their
 // Go correspondents. If no XSD type was found, the type name itself is
 // returned.
+//
+// Once all definitions are registered, the result for every name is cached
+// for the rest of the build.
 func (b builder) findType(name string) interface{} {
-	name = stripNamespace(name)
-	if t, ok := b.complTypes[name]; ok {
+	if t, ok := b.resolved[name]; ok {
 		return t
 	}
-	if t, ok := b.simplTypes[name]; ok {
+	t := b.resolveType(name)
+	if b.resolved != nil {
+		b.resolved[name] = t
+		b.logResolved(name, t)
+	}
+	return t
+}
+
+// logResolved logs what a type name resolves to, once per name.
+func (b builder) logResolved(name string, t interface{}) {
+	switch t := t.(type) {
+	case xsdComplexType:
+		b.logf("type '%s' resolves to complexType '%s'", name, t.Name)
+	case xsdSimpleType:
+		b.logf("type '%s' resolves to simpleType '%s'", name, t.Name)
+	case string:
+		if builtinType(t) {
+			b.logf("type '%s' resolves to Go type %s", name, t)
+		}
+	}
+}
+
+// logf writes a line to Options.Log, if set, prefixed by the top-level
+// definition being built.
+func (b builder) logf(format string, args ...interface{}) {
+	if b.log == nil {
+		return
+	}
+	if *b.building != "" {
+		fmt.Fprintf(b.log, "%s: ", *b.building)
+	}
+	fmt.Fprintf(b.log, format+"\n", args...)
+}
+
+// resolveType looks up the definition or built-in type of a type name for
+// findType.
+func (b builder) resolveType(name string) interface{} {
+	ns, name := splitQName(name)
+	if t, ok := b.complTypes[qname{ns, name}]; ok && ns != "" {
+		return t
+	}
+	if t, ok := b.simplTypes[qname{ns, name}]; ok && ns != "" {
+		return t
+	}
+
+	// A reference without a known namespace, or to a namespace without
+	// such a type, as from a schema included into another namespace, is
+	// resolved by local name. Built-in types are never shadowed by the
+	// types of a schema.
+	if t, ok := b.mappedGoType(name); ok && (ns == "" || ns == xsdNamespace) {
+		return t
+	}
+	if q, ok := b.typeNames[name]; ok {
+		if t, ok := b.complTypes[q]; ok {
+			return t
+		}
+		if t, ok := b.simplTypes[q]; ok {
+			return t
+		}
+	}
+	if t, ok := b.mappedGoType(name); ok {
 		return t
 	}
+	return name
+}
+
+// mappedGoType returns the Go type of a built-in XSD type, or the type it is
+// mapped to with Options.TypeMap.
+func (b builder) mappedGoType(name string) (string, bool) {
+	if t, ok := b.typeMap[name]; ok {
+		return t, true
+	}
+	return builtinGoType(name)
+}
 
+// builtinGoType returns the Go type of a built-in XSD type.
+func builtinGoType(name string) (string, bool) {
 	switch name {
 	case "boolean":
-		return "bool"
-	case "language", "Name", "token", "duration", "anyURI":
-		return "string"
-	case "long", "short", "integer", "int":
-		return "int"
+		return "bool", true
+	case "anySimpleType", "string", "normalizedString", "token", "language", "anyURI",
+		"Name", "NCName", "QName", "NMTOKEN", "NMTOKENS", "NOTATION",
+		"ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES":
+		return "string", true
+	case "byte":
+		return "int8", true
+	case "unsignedByte":
+		return "uint8", true
+	case "short":
+		return "int16", true
 	case "unsignedShort":
-		return "uint16"
-	case "decimal":
-		return "float64"
+		return "uint16", true
+	case "int":
+		return "int32", true
+	case "unsignedInt":
+		return "uint32", true
+	case "long":
+		return "int64", true
+	case "unsignedLong":
+		return "uint64", true
+	case "integer", "negativeInteger", "nonPositiveInteger":
+		return "int", true
+	case "nonNegativeInteger", "positiveInteger":
+		return "uint", true
+	case "decimal", "double":
+		return "float64", true
+	case "float":
+		return "float32", true
 	case "dateTime":
-		return "time.Time"
+		return "time.Time", true
+	case "date":
+		return dateType, true
+	case "time", "duration", "gYear", "gYearMonth", "gMonth", "gMonthDay", "gDay":
+		// time.Time only decodes RFC 3339 timestamps, and time.Duration
+		// integer nanoseconds, so these keep their lexical form
+		return "string", true
+	case "base64Binary", "hexBinary":
+		return "[]byte", true
 	default:
-		return name
+		return "", false
 	}
 }
 
+// abstractNote returns a documentation note for elements of an abstract
+// complex type. Their concrete type is named by xsi:type, which the
+// generated struct ignores, so only the fields of the abstract type are
+// decoded.
+func (b builder) abstractNote(name string) string {
+	note := fmt.Sprintf("The type %s is abstract: the concrete type of the element is named by its xsi:type attribute", name)
+	if derived := b.derivedTypes(name); len(derived) > 0 {
+		note += ", one of " + strings.Join(derived, ", ")
+	}
+	return note + fmt.Sprintf(". Only the fields of %s are decoded.", name)
+}
+
+// derivedTypes returns the names of the complex types that are not
+// abstract and derive from a complex type, directly or through others.
+func (b builder) derivedTypes(name string) []string {
+	var names []string
+	for _, s := range b.schemas {
+		for _, t := range s.ComplexTypes {
+			c := t.ComplexContent
+			if c == nil {
+				continue
+			}
+			var base string
+			if c.Extension != nil {
+				base = c.Extension.Base
+			} else if c.Restriction != nil {
+				base = c.Restriction.Base
+			}
+			if base == "" || stripNamespace(base) != name || t.Name == name {
+				continue
+			}
+			if !t.Abstract {
+				names = append(names, t.Name)
+			}
+			names = append(names, b.derivedTypes(t.Name)...)
+		}
+	}
+	return names
+}
+
+// binaryNote returns a documentation note for values of the XSD binary
+// types, which are left encoded as []byte since encoding/xml copies the
+// character data verbatim.
+func binaryNote(name string) string {
+	switch stripNamespace(name) {
+	case "base64Binary":
+		return "Holds the base64 encoded value, encoding/xml does not decode it."
+	case "hexBinary":
+		return "Holds the hex encoded value, encoding/xml does not decode it."
+	}
+	return ""
+}
+
+// joinDoc appends a paragraph to documentation text.
+func joinDoc(doc, note string) string {
+	if doc == "" || note == "" {
+		return doc + note
+	}
+	return doc + "\n" + note
+}
+
+// constType reports whether values of the Go type name can be declared as
+// constants, which is required for generating enumerations.
+func constType(name string) bool {
+	switch name {
+	case "string", "float32", "float64",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64":
+		return true
+	}
+	return false
+}
+
 func stripNamespace(name string) string {
-	if s := strings.Split(name, ":"); len(s) > 1 {
-		return s[len(s)-1]
+	if i := strings.Index(name, "}"); strings.HasPrefix(name, "{") && i > 0 {
+		return name[i+1:]
 	}
-	return name
+	return name[strings.LastIndexByte(name, ':')+1:]
 }
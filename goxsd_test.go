@@ -1,9 +1,20 @@
-package main
+package goxsd
 
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
 
@@ -61,29 +72,35 @@ var (
 	</complexType>
 </schema>`,
 			xml: xmlTree{
-				Name: "titleList",
-				Type: "titleList",
+				Name:     "titleList",
+				Root:     true,
+				Type:     "titleListType",
+				TypeName: "titleListType",
+				Source:   "complexType 'titleListType'",
 				Children: []*xmlTree{
 					&xmlTree{
-						Name:  "title",
-						Type:  "string",
-						Cdata: true,
-						List:  true,
+						Name:     "title",
+						Type:     "string",
+						TypeName: "originalTitleType",
+						Source:   "complexType 'originalTitleType'",
+						Cdata:    true,
+						List:     true,
 						Attribs: []xmlAttrib{
-							{Name: "language", Type: "string"},
-							{Name: "original", Type: "bool"},
+							{Name: "language", Type: "string", Optional: true},
+							{Name: "original", Type: "bool", Optional: true},
 						},
 					},
 				},
 			},
 			gosrc: `
-type titleList struct {
-	Title []title ` + "`xml:\"title\"`" + `
+type titleListType struct {
+	XMLName xml.Name ` + "`xml:\"titleList\"`" + `
+	Title []originalTitleType ` + "`xml:\"title\"`" + `
 }
 
-type title struct {
-	Language string ` + "`xml:\"language,attr\"`" + `
-	Original bool ` + "`xml:\"original,attr\"`" + `
+type originalTitleType struct {
+	Language string ` + "`xml:\"language,attr,omitempty\"`" + `
+	Original bool ` + "`xml:\"original,attr,omitempty\"`" + `
 	Title    string ` + "`xml:\",chardata\"`" + `
 }
 
@@ -119,14 +136,19 @@ type title struct {
 	</simpleType>
 </schema>`,
 			xml: xmlTree{
-				Name: "tagList",
-				Type: "tagList",
+				Name:   "tagList",
+				Root:   true,
+				Source: "the complexType of element 'tagList'",
 				Children: []*xmlTree{
 					&xmlTree{
-						Name:  "tag",
-						Type:  "string",
-						List:  true,
-						Cdata: true,
+						Name:     "tag",
+						Type:     "string",
+						TypeName: "tagReferenceType",
+						Source:   "complexType 'tagReferenceType'",
+						List:     true,
+						Optional: true,
+						Cdata:    true,
+						Facets:   &xmlFacets{Patterns: []string{`[0-9a-zA-Z\-]+`}, MaxLength: -1},
 						Attribs: []xmlAttrib{
 							{Name: "type", Type: "string"},
 						},
@@ -135,10 +157,11 @@ type title struct {
 			},
 			gosrc: `
 type tagList struct {
-	Tag []tag ` + "`xml:\"tag\"`" + `
+	XMLName xml.Name ` + "`xml:\"tagList\"`" + `
+	Tag []tagReferenceType ` + "`xml:\"tag,omitempty\"`" + `
 }
 
-type tag struct {
+type tagReferenceType struct {
 	Type string ` + "`xml:\"type,attr\"`" + `
 	Tag string ` + "`xml:\",chardata\"`" + `
 }
@@ -159,16 +182,20 @@ type tag struct {
 	</complexType>
 </schema>`,
 			xml: xmlTree{
-				Name:  "tagId",
-				Type:  "string",
-				List:  false,
-				Cdata: true,
+				Name:     "tagId",
+				Root:     true,
+				Type:     "string",
+				TypeName: "tagReferenceType",
+				Source:   "complexType 'tagReferenceType'",
+				List:     false,
+				Cdata:    true,
 				Attribs: []xmlAttrib{
 					{Name: "type", Type: "string"},
 				},
 			},
 			gosrc: `
-type tagID struct {
+type tagReferenceType struct {
+	XMLName xml.Name ` + "`xml:\"tagId\"`" + `
 	Type string ` + "`xml:\"type,attr\"`" + `
 	TagID string ` + "`xml:\",chardata\"`" + `
 }
@@ -189,24 +216,444 @@ type tagID struct {
 	</complexType>
 </schema>`,
 			xml: xmlTree{
-				Name:  "url",
-				Type:  "string",
-				List:  false,
-				Cdata: true,
+				Name:     "url",
+				Root:     true,
+				Type:     "string",
+				TypeName: "tagReferenceType",
+				Source:   "complexType 'tagReferenceType'",
+				List:     false,
+				Cdata:    true,
 				Attribs: []xmlAttrib{
 					{Name: "type", Type: "string"},
 				},
 			},
 			gosrc: `
-type XxxURL struct {
+type XxxTagReferenceType struct {
+	XMLName xml.Name ` + "`xml:\"url\"`" + `
 	Type string ` + "`xml:\"type,attr\"`" + `
 	URL string ` + "`xml:\",chardata\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="ticket">
+		<complexType>
+			<sequence>
+				<element name="status" type="statusType" />
+			</sequence>
+		</complexType>
+	</element>
+	<simpleType name="statusType">
+		<restriction base="string">
+			<enumeration value="open" />
+			<enumeration value="in-progress" />
+			<enumeration value="closed" />
+		</restriction>
+	</simpleType>
+</schema>`,
+			xml: xmlTree{
+				Name:   "ticket",
+				Root:   true,
+				Source: "the complexType of element 'ticket'",
+				Children: []*xmlTree{
+					&xmlTree{
+						Name:     "status",
+						Type:     "string",
+						TypeName: "statusType",
+						Enums:    []string{"open", "in-progress", "closed"},
+						Source:   "simpleType 'statusType'",
+					},
+				},
+			},
+			gosrc: `
+type ticket struct {
+	XMLName xml.Name ` + "`xml:\"ticket\"`" + `
+	Status statusType ` + "`xml:\"status\"`" + `
+}
+
+type statusType string
+
+const (
+	statusTypeOpen statusType = "open"
+	statusTypeInProgress statusType = "in-progress"
+	statusTypeClosed statusType = "closed"
+)
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="customer">
+		<complexType>
+			<sequence>
+				<element name="name" type="string" />
+				<element name="note" type="string" minOccurs="0" />
+				<element name="address" type="addressType" minOccurs="0" />
+			</sequence>
+		</complexType>
+	</element>
+	<complexType name="addressType">
+		<sequence>
+			<element name="street" type="string" />
+		</sequence>
+	</complexType>
+</schema>`,
+			xml: xmlTree{
+				Name:   "customer",
+				Root:   true,
+				Source: "the complexType of element 'customer'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "name", Type: "string"},
+					&xmlTree{Name: "note", Type: "string", Optional: true},
+					&xmlTree{
+						Name:     "address",
+						Type:     "addressType",
+						TypeName: "addressType",
+						Source:   "complexType 'addressType'",
+						Optional: true,
+						Children: []*xmlTree{
+							&xmlTree{Name: "street", Type: "string"},
+						},
+					},
+				},
+			},
+			gosrc: `
+type customer struct {
+	XMLName xml.Name ` + "`xml:\"customer\"`" + `
+	Name string ` + "`xml:\"name\"`" + `
+	Note *string ` + "`xml:\"note,omitempty\"`" + `
+	Address *addressType ` + "`xml:\"address,omitempty\"`" + `
+}
+
+type addressType struct {
+	Street string ` + "`xml:\"street\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="amount" type="decimal" />
+				<choice>
+					<element name="card" type="string" />
+					<element name="cheque" type="string" />
+				</choice>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+			xml: xmlTree{
+				Name:   "order",
+				Root:   true,
+				Source: "the complexType of element 'order'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "amount", Type: "float64"},
+					&xmlTree{Name: "card", Type: "string", Optional: true},
+					&xmlTree{Name: "cheque", Type: "string", Optional: true},
+				},
+			},
+			gosrc: `
+type order struct {
+	XMLName xml.Name ` + "`xml:\"order\"`" + `
+	Amount float64 ` + "`xml:\"amount\"`" + `
+	Card *string ` + "`xml:\"card,omitempty\"`" + `
+	Cheque *string ` + "`xml:\"cheque,omitempty\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="contact" type="contactType" />
+	<complexType name="contactType">
+		<choice>
+			<element name="email" type="string" />
+			<element name="phone" type="phoneType" />
+		</choice>
+	</complexType>
+	<complexType name="phoneType">
+		<sequence>
+			<element name="number" type="string" />
+		</sequence>
+	</complexType>
+</schema>`,
+			xml: xmlTree{
+				Name:     "contact",
+				Root:     true,
+				Type:     "contactType",
+				TypeName: "contactType",
+				Source:   "complexType 'contactType'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "email", Type: "string", Optional: true},
+					&xmlTree{
+						Name:     "phone",
+						Type:     "phoneType",
+						TypeName: "phoneType",
+						Source:   "complexType 'phoneType'",
+						Optional: true,
+						Children: []*xmlTree{
+							&xmlTree{Name: "number", Type: "string"},
+						},
+					},
+				},
+			},
+			gosrc: `
+type contactType struct {
+	XMLName xml.Name ` + "`xml:\"contact\"`" + `
+	Email *string ` + "`xml:\"email,omitempty\"`" + `
+	Phone *phoneType ` + "`xml:\"phone,omitempty\"`" + `
+}
+
+type phoneType struct {
+	Number string ` + "`xml:\"number\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="person" type="personType" />
+	<complexType name="personType">
+		<all>
+			<element name="firstName" type="string" />
+			<element name="age" type="int" minOccurs="0" />
+		</all>
+	</complexType>
+</schema>`,
+			xml: xmlTree{
+				Name:     "person",
+				Root:     true,
+				Type:     "personType",
+				TypeName: "personType",
+				Source:   "complexType 'personType'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "firstName", Type: "string"},
+					&xmlTree{Name: "age", Type: "int32", Optional: true},
+				},
+			},
+			gosrc: `
+type personType struct {
+	XMLName xml.Name ` + "`xml:\"person\"`" + `
+	FirstName string ` + "`xml:\"firstName\"`" + `
+	Age *int32 ` + "`xml:\"age,omitempty\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="folder" type="folderType" />
+	<complexType name="folderType">
+		<sequence>
+			<element name="name" type="string" />
+			<element name="file" type="fileType" maxOccurs="unbounded" />
+		</sequence>
+	</complexType>
+	<complexType name="fileType">
+		<sequence>
+			<element name="parent" type="folderType" />
+		</sequence>
+	</complexType>
+</schema>`,
+			xml: xmlTree{
+				Name:     "folder",
+				Type:     "folderType",
+				TypeName: "folderType",
+				Source:   "complexType 'folderType'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "name", Type: "string"},
+					&xmlTree{
+						Name:     "file",
+						Type:     "fileType",
+						TypeName: "fileType",
+						Source:   "complexType 'fileType'",
+						List:     true,
+						Children: []*xmlTree{
+							&xmlTree{Name: "parent", Type: "folderType", TypeName: "folderType", Source: "complexType 'folderType'", Ref: true},
+						},
+					},
+				},
+			},
+			gosrc: `
+type folderType struct {
+	Name string ` + "`xml:\"name\"`" + `
+	File []fileType ` + "`xml:\"file\"`" + `
+}
+
+type fileType struct {
+	Parent *folderType ` + "`xml:\"parent\"`" + `
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="series">
+		<complexType>
+			<sequence>
+				<element name="values" type="intList" />
+				<element name="labels" minOccurs="0">
+					<simpleType>
+						<list itemType="string" />
+					</simpleType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<simpleType name="intList">
+		<list itemType="xsd:int" />
+	</simpleType>
+</schema>`,
+			xml: xmlTree{
+				Name:   "series",
+				Root:   true,
+				Source: "the complexType of element 'series'",
+				Children: []*xmlTree{
+					&xmlTree{Name: "values", Type: "int32", SimpleList: true, Source: "simpleType 'intList'"},
+					&xmlTree{Name: "labels", Type: "string", Optional: true, SimpleList: true, Source: "the simpleType of element 'labels'"},
+				},
+			},
+			gosrc: `
+type series struct {
+	XMLName xml.Name ` + "`xml:\"series\"`" + `
+	Values int32List ` + "`xml:\"values\"`" + `
+	Labels stringList ` + "`xml:\"labels,omitempty\"`" + `
+}
+
+type int32List []int32
+
+func (l *int32List) UnmarshalText(text []byte) error {
+	var items int32List
+	for _, s := range strings.Fields(string(text)) {
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		items = append(items, int32(v))
+	}
+	*l = items
+	return nil
+}
+
+func (l int32List) MarshalText() ([]byte, error) {
+	s := make([]string, len(l))
+	for i, v := range l {
+		s[i] = strconv.FormatInt(int64(v), 10)
+	}
+	return []byte(strings.Join(s, " ")), nil
+}
+
+type stringList []string
+
+func (l *stringList) UnmarshalText(text []byte) error {
+	var items stringList
+	for _, s := range strings.Fields(string(text)) {
+		items = append(items, s)
+	}
+	*l = items
+	return nil
+}
+
+func (l stringList) MarshalText() ([]byte, error) {
+	s := make([]string, len(l))
+	for i, v := range l {
+		s[i] = v
+	}
+	return []byte(strings.Join(s, " ")), nil
+}
+			`,
+		},
+
+		{
+			exported: false,
+			prefix:   "",
+			xsd: `<schema>
+	<element name="para" type="paraType" />
+	<complexType name="paraType" mixed="true">
+		<sequence>
+			<element name="em" type="string" minOccurs="0" maxOccurs="unbounded" />
+		</sequence>
+	</complexType>
+</schema>`,
+			xml: xmlTree{
+				Name:     "para",
+				Root:     true,
+				Type:     "string",
+				TypeName: "paraType",
+				Source:   "complexType 'paraType'",
+				Cdata:    true,
+				Children: []*xmlTree{
+					&xmlTree{Name: "em", Type: "string", List: true, Optional: true},
+				},
+			},
+			gosrc: `
+type paraType struct {
+	XMLName xml.Name ` + "`xml:\"para\"`" + `
+	Em []string ` + "`xml:\"em,omitempty\"`" + `
+	Para string ` + "`xml:\",chardata\"`" + `
 }
 			`,
 		},
 	}
 )
 
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestGolden generates Go source for every schema in testdata and compares
+// it to the golden file next to it, which -update rewrites instead.
+func TestGolden(t *testing.T) {
+	schemas, err := filepath.Glob(filepath.Join("testdata", "*.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) == 0 {
+		t.Fatal("no schemas in testdata")
+	}
+
+	for _, xsd := range schemas {
+		var out bytes.Buffer
+		if err := Generate(&out, xsd, Options{Package: "golden", ChardataName: "Value"}); err != nil {
+			t.Errorf("%s: %s", xsd, err)
+			continue
+		}
+
+		golden := strings.TrimSuffix(xsd, ".xsd") + ".go"
+		if *update {
+			if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != string(want) {
+			t.Errorf("Generated Go source for %s differs from %s (rerun with -update if expected)", xsd, golden)
+			t.Logf(got)
+		}
+	}
+}
+
 func removeComments(buf bytes.Buffer) bytes.Buffer {
 	lines := strings.Split(buf.String(), "\n")
 	for i, l := range lines {
@@ -224,14 +671,13 @@ func TestBuildXmlElem(t *testing.T) {
 			t.Error(err)
 		}
 
-		bldr := builder{
-			schemas:    []xsdSchema{schema},
-			complTypes: make(map[string]xsdComplexType),
-			simplTypes: make(map[string]xsdSimpleType),
+		bldr := newBuilder([]xsdSchema{schema})
+		elems, err := bldr.buildXML()
+		if err != nil {
+			t.Fatal(err)
 		}
-		elems := bldr.buildXML()
-		if len(elems) != 1 {
-			t.Errorf("wrong number of xml elements")
+		if len(elems) == 0 {
+			t.Fatalf("wrong number of xml elements")
 		}
 		e := elems[0]
 		if !reflect.DeepEqual(tst.xml, *e) {
@@ -271,6 +717,29 @@ func TestLintTitle(t *testing.T) {
 	}
 }
 
+func TestFieldName(t *testing.T) {
+	for i, tt := range []struct {
+		input, want string
+	}{
+		{"name", "Name"},
+		{"Name", "Name"},
+		{"_internal", "X_internal"},
+		{"1stLine", "X1stLine"},
+		{"élan", "Élan"},
+		{"order-id", "OrderID"},
+		{"x.y", "XY"},
+		{"ship.to-address", "ShipToAddress"},
+		{"a--b..c", "ABC"},
+		{"-leading", "Leading"},
+		{"customer_name", "Customer_name"},
+		{"---", "X"},
+	} {
+		if got := fieldName(tt.input); got != tt.want {
+			t.Errorf("[%d] fieldName(%q) = %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
 func TestSquish(t *testing.T) {
 	for i, tt := range []struct {
 		input, want string
@@ -310,3 +779,4629 @@ func TestReplace(t *testing.T) {
 		i++
 	}
 }
+
+func TestInitialisms(t *testing.T) {
+	for i, tt := range []struct {
+		input, want string
+	}{
+		{"httpUrl", "HTTPURL"},
+		{"userId", "UserID"},
+		{"userID", "UserID"},
+		{"HTTPUrl", "HTTPURL"},
+		{"xmlHttpRequest", "XMLHTTPRequest"},
+		{"api-key", "APIKey"},
+		{"utf8Text", "UTF8Text"},
+		{"identity", "Identity"},
+		{"guide", "Guide"},
+		{"uint", "Uint"},
+		{"skuCode", "SkuCode"},
+	} {
+		if got := fieldName(tt.input); got != tt.want {
+			t.Errorf("[%d] fieldName(%q) = %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+
+	s := newInitialisms([]string{"sku", "EAN"})
+	for i, tt := range []struct {
+		input, want string
+	}{
+		{"skuCode", "SKUCode"},
+		{"productEan", "ProductEAN"},
+		{"userId", "UserID"},
+	} {
+		if got := s.fieldName(tt.input); got != tt.want {
+			t.Errorf("[%d] fieldName(%q) = %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNoInitialisms(t *testing.T) {
+	schema := `<schema>
+	<element name="httpRequest">
+		<complexType>
+			<sequence>
+				<element name="userId" type="string" />
+				<element name="api-key" type="string" />
+				<element name="URL" type="anyURI" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Exported: true, NoInitialisms: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type HttpRequest struct",
+		"UserId string `xml:\"userId\"`",
+		"ApiKey string `xml:\"api-key\"`",
+		"URL string `xml:\"URL\"`",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	err := GenerateFrom(ioutil.Discard, strings.NewReader(schema), Options{NoInitialisms: true, Initialisms: []string{"sku"}})
+	if err == nil {
+		t.Error("Expected an error for initialisms that are not upper cased")
+	}
+}
+
+func TestFindType(t *testing.T) {
+	b := newBuilder(nil)
+	for i, tt := range []struct {
+		input, want string
+	}{
+		{"xsd:date", dateType},
+		{"xsd:dateTime", "time.Time"},
+		{"xsd:time", "string"},
+		{"xsd:duration", "string"},
+		{"xsd:gYear", "string"},
+		{"xsd:gMonthDay", "string"},
+		{"xsd:base64Binary", "[]byte"},
+		{"xsd:hexBinary", "[]byte"},
+		{"xsd:byte", "int8"},
+		{"xsd:unsignedByte", "uint8"},
+		{"xsd:short", "int16"},
+		{"xsd:unsignedShort", "uint16"},
+		{"xsd:int", "int32"},
+		{"xsd:unsignedInt", "uint32"},
+		{"xsd:long", "int64"},
+		{"xsd:unsignedLong", "uint64"},
+		{"xsd:integer", "int"},
+		{"xsd:negativeInteger", "int"},
+		{"xsd:nonPositiveInteger", "int"},
+		{"xsd:nonNegativeInteger", "uint"},
+		{"xsd:positiveInteger", "uint"},
+		{"xsd:float", "float32"},
+		{"xsd:double", "float64"},
+	} {
+		if got := b.findType(tt.input); got != tt.want {
+			t.Errorf("[%d] findType(%q) = %q, want %q", i, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTemporalDecoding(t *testing.T) {
+	schema := `<schema>
+	<element name="event">
+		<complexType>
+			<sequence>
+				<element name="at" type="dateTime" />
+				<element name="day" type="date" />
+				<element name="clock" type="time" />
+				<element name="length" type="duration" />
+				<element name="year" type="gYear" />
+			</sequence>
+			<attribute name="until" type="date" />
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	var e event
+	doc := "<event until=\"2024-02-01+05:00\"><at>2024-01-02T03:04:05Z</at><day>2024-01-02</day><clock>03:04:05</clock><length>PT1H</length><year>2024</year></event>"
+	if err := xml.Unmarshal([]byte(doc), &e); err != nil {
+		panic(err)
+	}
+	fmt.Println(e.At.Format(time.Kitchen), e.Day, e.Clock, e.Length, e.Year)
+	fmt.Println(e.Day.Weekday(), e.Until.Format(time.RFC3339))
+
+	out, err := xml.Marshal(e)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout.Write(out)
+	fmt.Println()
+
+	js, err := json.Marshal(e.Day)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(js))
+
+	if err := xml.Unmarshal([]byte("<event><day>2024-01-02T03:04:05Z</day></event>"), &e); err == nil {
+		panic("expected an error for a date with a time")
+	}
+}
+`)
+	want := "3:04AM 2024-01-02 03:04:05 PT1H 2024\n" +
+		"Tuesday 2024-02-01T00:00:00+05:00\n" +
+		`<event until="2024-02-01"><at>2024-01-02T03:04:05Z</at><day>2024-01-02</day><clock>03:04:05</clock><length>PT1H</length><year>2024</year></event>` + "\n" +
+		`"2024-01-02"` + "\n"
+	if out != want {
+		t.Errorf("Decoding temporal types gave %q, want %q", out, want)
+	}
+}
+
+// generatedImports returns the import paths that the source generated for
+// the given trees refers to.
+func generatedImports(t *testing.T, g generator, roots []*xmlTree) []string {
+	tt, err := g.prepare()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range roots {
+		if err := g.execute(e, tt, ioutil.Discard, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g.used.take()
+}
+
+func TestGeneratedImports(t *testing.T) {
+	withTime := &xmlTree{
+		Name: "event",
+		Type: "event",
+		Children: []*xmlTree{
+			{Name: "start", Type: "time.Time"},
+			{Name: "length", Type: "time.Duration"},
+		},
+	}
+	if got := generatedImports(t, generator{}, []*xmlTree{withTime}); !reflect.DeepEqual(got, []string{"time"}) {
+		t.Errorf("generatedImports = %q, want %q", got, []string{"time"})
+	}
+
+	for _, tst := range tests {
+		var want []string
+		if tst.xml.Root {
+			want = []string{"encoding/xml"}
+		}
+		if tst.xml.Name == "series" {
+			// The types of its lists parse and join their values
+			want = append(want, "strconv", "strings")
+		}
+		if got := generatedImports(t, generator{}, []*xmlTree{&tst.xml}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Unexpected imports for %s: %q", tst.xml.Name, got)
+		}
+	}
+
+	// A struct that is no root, nor collects attributes, still decodes
+	// its choice with encoding/xml, also in a file of its own
+	schema := `<schema>
+	<complexType name="shape">
+		<choice>
+			<element name="label" type="string" />
+			<element name="size" type="int" />
+		</choice>
+	</complexType>
+	<complexType name="event">
+		<sequence>
+			<element name="at" type="dateTime" />
+		</sequence>
+	</complexType>
+</schema>`
+	files, err := GenerateFilesFrom(strings.NewReader(schema), Options{Package: "shapes", Choice: ChoiceInterface})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, imp := range map[string]string{
+		"shape.go": "import (\n\t\"encoding/xml\"\n)",
+		"event.go": "import (\n\t\"time\"\n)",
+	} {
+		if !strings.Contains(string(files[file]), imp) {
+			t.Errorf("Missing %q in %s\n%s", imp, file, files[file])
+		}
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	root := &xmlTree{
+		Name: "purchase-order",
+		Type: "purchase-order",
+		Attribs: []xmlAttrib{
+			{Name: "order-id", Type: "string"},
+			{Name: "note", Type: "string", Optional: true},
+		},
+		Children: []*xmlTree{
+			{Name: "ship-to", Type: "string"},
+			{Name: "comment", Type: "string", Cdata: true},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := strings.Join(strings.Fields(out.String()), "")
+	for _, want := range []string{
+		"OrderIDstring`xml:\"order-id,attr\"`",
+		"Notestring`xml:\"note,attr,omitempty\"`",
+		"ShipTostring`xml:\"ship-to\"`",
+		"Commentcomment`xml:\"comment\"`",
+		"Commentstring`xml:\",chardata\"`",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestAttributeUse(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="item">
+		<complexType>
+			<attribute name="id" type="string" use="required" />
+			<attribute name="label" type="string" use="optional" />
+			<attribute name="color" type="string" />
+			<attribute name="legacy" type="string" use="prohibited" />
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	bldr := newBuilder([]xsdSchema{schema})
+	elems, err := bldr.buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xmlAttrib{
+		{Name: "id", Type: "string"},
+		{Name: "label", Type: "string", Optional: true},
+		{Name: "color", Type: "string", Optional: true},
+	}
+	if !reflect.DeepEqual(elems[0].Attribs, want) {
+		t.Errorf("Unexpected attributes: %#v", elems[0].Attribs)
+	}
+}
+
+func TestAttributeGroups(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="link">
+		<complexType>
+			<attribute name="href" type="anyURI" use="required" />
+			<attributeGroup ref="common" />
+		</complexType>
+	</element>
+	<attributeGroup name="common">
+		<attribute name="id" type="string" />
+		<attributeGroup ref="i18n" />
+	</attributeGroup>
+	<attributeGroup name="i18n">
+		<attribute name="lang" type="language" />
+		<attributeGroup ref="common" />
+	</attributeGroup>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xmlAttrib{
+		{Name: "href", Type: "string"},
+		{Name: "id", Type: "string", Optional: true},
+		{Name: "lang", Type: "string", Optional: true},
+	}
+	if !reflect.DeepEqual(elems[0].Attribs, want) {
+		t.Errorf("Unexpected attributes: %#v", elems[0].Attribs)
+	}
+}
+
+func TestModelGroups(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="invoice" type="invoiceType" />
+	<element name="receipt" type="receiptType" />
+	<complexType name="invoiceType">
+		<sequence>
+			<element name="number" type="string" />
+			<group ref="partyGroup" />
+		</sequence>
+	</complexType>
+	<complexType name="receiptType">
+		<sequence>
+			<group ref="partyGroup" />
+		</sequence>
+	</complexType>
+	<group name="partyGroup">
+		<sequence>
+			<element name="seller" type="string" />
+			<element name="buyer" type="string" />
+		</sequence>
+	</group>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 2 {
+		t.Fatalf("wrong number of xml elements")
+	}
+	for i, want := range [][]string{
+		{"number", "seller", "buyer"},
+		{"seller", "buyer"},
+	} {
+		var got []string
+		for _, c := range elems[i].Children {
+			got = append(got, c.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unexpected children of %s: %q, want %q", elems[i].Name, got, want)
+		}
+	}
+}
+
+func TestGenerateUnformatted(t *testing.T) {
+	var out bytes.Buffer
+	g := generator{pkg: "not valid"}
+	if err := g.do(&out, []*xmlTree{&tests[0].xml}); err == nil {
+		t.Fatal("Expected a format error")
+	}
+	if !strings.Contains(out.String(), "type titleListType struct") {
+		t.Errorf("Expected the unformatted source, got: %s", out.String())
+	}
+}
+
+func TestNamedComplexTypes(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="shipment">
+		<complexType>
+			<sequence>
+				<element name="from" type="addressType" />
+				<element name="to" type="addressType" />
+			</sequence>
+		</complexType>
+	</element>
+	<complexType name="addressType">
+		<sequence>
+			<element name="street" type="string" />
+		</sequence>
+	</complexType>
+	<complexType name="noteType">
+		<sequence>
+			<element name="text" type="string" />
+		</sequence>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 2 || elems[1].TypeName != "noteType" {
+		t.Fatalf("Expected the unused noteType as a second tree, got %d trees", len(elems))
+	}
+
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, elems); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type shipment struct {
+	XMLName xml.Name ` + "`xml:\"shipment\"`" + `
+	From addressType ` + "`xml:\"from\"`" + `
+	To addressType ` + "`xml:\"to\"`" + `
+}
+
+type addressType struct {
+	Street string ` + "`xml:\"street\"`" + `
+}
+
+type noteType struct {
+	Text string ` + "`xml:\"text\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+func TestUnionType(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="size">
+		<complexType>
+			<sequence>
+				<element name="value" type="sizeType" />
+			</sequence>
+			<attribute name="unit" type="sizeType" />
+		</complexType>
+	</element>
+	<simpleType name="sizeType">
+		<union memberTypes="xsd:int xsd:string" />
+	</simpleType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := elems[0].Children[0].Type; got != "string" {
+		t.Errorf("Unexpected element type %q, want %q", got, "string")
+	}
+	if got := elems[0].Attribs[0].Type; got != "string" {
+		t.Errorf("Unexpected attribute type %q, want %q", got, "string")
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="book" type="bookType">
+		<annotation>
+			<documentation>A book in the catalog.</documentation>
+		</annotation>
+	</element>
+	<complexType name="bookType">
+		<annotation>
+			<documentation>
+				A published work.
+				Books are identified by their ISBN.
+			</documentation>
+		</annotation>
+		<sequence>
+			<element name="title" type="string">
+				<annotation>
+					<documentation>The full title of the book, including any subtitle, as printed on the title page rather than the cover.</documentation>
+				</annotation>
+			</element>
+		</sequence>
+		<attribute name="isbn" type="string">
+			<annotation>
+				<documentation>The 13 digit ISBN.</documentation>
+			</annotation>
+		</attribute>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (generator{pkg: "test"}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"// bookType is generated from an XSD element\n//\n// A published work.\n// Books are identified by their ISBN.\ntype bookType struct {",
+		"\t// The 13 digit ISBN.\n\tIsbn string",
+		"\t// The full title of the book, including any subtitle, as printed on the title\n\t// page rather than the cover.\n\tTitle string",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestPrefixPrimitives(t *testing.T) {
+	root := &xmlTree{
+		Name:     "event",
+		Type:     "eventType",
+		TypeName: "eventType",
+		Attribs:  []xmlAttrib{{Name: "id", Type: "int"}},
+		Children: []*xmlTree{
+			{Name: "start", Type: "time.Time"},
+			{Name: "tags", Type: "string", SimpleList: true},
+			{Name: "place", Type: "placeType", TypeName: "placeType", Ref: true},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{prefix: "ev", exported: true}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := strings.Join(strings.Fields(out.String()), "")
+	for _, want := range []string{
+		"typeEvEventTypestruct{",
+		"IDint`",
+		"Starttime.Time`",
+		"TagsEvStringList`",
+		"typeEvStringList[]string",
+		"Place*EvPlaceType`",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestBinaryTypes(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="blob">
+		<complexType>
+			<sequence>
+				<element name="data" type="xsd:base64Binary" />
+			</sequence>
+			<attribute name="checksum" type="xsd:hexBinary" />
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (generator{pkg: "test"}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+
+	src := strings.Join(strings.Fields(out.String()), " ")
+	for _, want := range []string{
+		"// Holds the hex encoded value, encoding/xml does not decode it. Checksum []byte",
+		"// Holds the base64 encoded value, encoding/xml does not decode it. Data []byte",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestParseImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a imports b and c, which both import d, which imports a and itself
+	for name, src := range map[string]string{
+		"a.xsd": `<schema><import schemaLocation="b.xsd" /><include schemaLocation="c.xsd" /><import namespace="urn:none" /><complexType name="a" /></schema>`,
+		"b.xsd": `<schema><import schemaLocation="d.xsd" /><complexType name="b" /></schema>`,
+		"c.xsd": `<schema><import schemaLocation="./d.xsd" /><complexType name="c" /></schema>`,
+		"d.xsd": `<schema><import schemaLocation="a.xsd" /><import schemaLocation="d.xsd" /><complexType name="d" /></schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "a.xsd"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range schemas {
+		for _, ct := range s.ComplexTypes {
+			got = append(got, ct.Name)
+		}
+	}
+	if want := []string{"a", "b", "d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected types %q, want %q", got, want)
+	}
+}
+
+func TestParseURLImports(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/main.xsd":
+			w.Write([]byte(`<schema><import schemaLocation="common/types.xsd" /><complexType name="main" /></schema>`))
+		case "/schemas/common/types.xsd":
+			w.Write([]byte(`<schema><complexType name="types" /></schema>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	schemas, err := parseXSDFile(srv.URL+"/schemas/main.xsd", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 || schemas[1].ComplexTypes[0].Name != "types" {
+		t.Errorf("Unexpected schemas: %#v", schemas)
+	}
+
+	if _, err := parseXSDFile(srv.URL+"/schemas/missing.xsd", false); err == nil {
+		t.Error("Expected an error for a missing schema")
+	}
+
+	if _, err := parseXSDFile(srv.URL+"/schemas/main.xsd", true); err == nil || !strings.Contains(err.Error(), "network access is disabled") {
+		t.Errorf("Expected a network access error, got %v", err)
+	}
+}
+
+func TestJSONTags(t *testing.T) {
+	root := &xmlTree{
+		Name:    "order-line",
+		Type:    "order-line",
+		Attribs: []xmlAttrib{{Name: "line-id", Type: "string"}},
+		Children: []*xmlTree{
+			{Name: "note", Type: "string", Optional: true},
+			{Name: "amount", Type: "float64", Cdata: true},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{json: true}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := strings.Join(strings.Fields(out.String()), "")
+	for _, want := range []string{
+		"LineIDstring`xml:\"line-id,attr\"json:\"LineID\"`",
+		"Note*string`xml:\"note,omitempty\"json:\"Note,omitempty\"`",
+		"Amountfloat64`xml:\",chardata\"json:\"Amount\"`",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestAttributeValues(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="price">
+		<complexType>
+			<attribute name="currency" type="string" default="EUR" />
+			<attribute name="version" type="string" fixed="1.0" />
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xmlAttrib{
+		{Name: "currency", Type: "string", Optional: true, Default: "EUR"},
+		{Name: "version", Type: "string", Optional: true, Fixed: "1.0"},
+	}
+	if !reflect.DeepEqual(elems[0].Attribs, want) {
+		t.Errorf("Unexpected attributes: %#v", elems[0].Attribs)
+	}
+
+	var out bytes.Buffer
+	if err := (generator{pkg: "test"}).do(&out, elems); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t// Defaults to \"EUR\" when absent.\n\tCurrency string",
+		"\t// Fixed to \"1.0\" by the schema.\n\tVersion string",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Generated source is missing %q", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestChardataName(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="amount" type="amountType" />
+	<complexType name="amountType">
+		<simpleContent>
+			<extension base="decimal">
+				<attribute name="currency" type="string" use="required" />
+			</extension>
+		</simpleContent>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	g := generator{cdataName: "Value", json: true}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type amountType struct {
+	XMLName xml.Name ` + "`xml:\"amount\" json:\"-\"`" + `
+	Currency string ` + "`xml:\"currency,attr\" json:\"Currency\"`" + `
+	Value float64 ` + "`xml:\",chardata\" json:\"Value\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+func TestDedupeInlineTypes(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="address">
+					<complexType>
+						<sequence>
+							<element name="street" type="string" />
+						</sequence>
+					</complexType>
+				</element>
+				<element name="item">
+					<complexType>
+						<attribute name="sku" type="string" />
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<element name="invoice">
+		<complexType>
+			<sequence>
+				<element name="address">
+					<complexType>
+						<sequence>
+							<element name="city" type="string" />
+						</sequence>
+					</complexType>
+				</element>
+				<element name="item">
+					<complexType>
+						<attribute name="sku" type="string" />
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (generator{}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type order struct {
+	XMLName xml.Name ` + "`xml:\"order\"`" + `
+	Address address ` + "`xml:\"address\"`" + `
+	Item item ` + "`xml:\"item\"`" + `
+}
+
+type address struct {
+	Street string ` + "`xml:\"street\"`" + `
+}
+
+type item struct {
+	Sku string ` + "`xml:\"sku,attr,omitempty\"`" + `
+}
+
+type invoice struct {
+	XMLName xml.Name ` + "`xml:\"invoice\"`" + `
+	Address address2 ` + "`xml:\"address\"`" + `
+	Item item ` + "`xml:\"item\"`" + `
+}
+
+type address2 struct {
+	City string ` + "`xml:\"city\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="order" type="orderType" />
+	<complexType name="orderType">
+		<sequence>
+			<element name="state" type="stateType" />
+		</sequence>
+	</complexType>
+	<simpleType name="stateType">
+		<restriction base="string">
+			<enumeration value="open" />
+		</restriction>
+	</simpleType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := (generator{pkg: "test", comments: true}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// orderType was generated from complexType 'orderType'\n",
+		"// stateType was generated from simpleType 'stateType'\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing %q in generated Go source", want)
+			t.Logf(out.String())
+		}
+	}
+
+	out.Reset()
+	if err := (generator{pkg: "test"}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "was generated from") {
+		t.Errorf("Unexpected source comment without -comments")
+	}
+}
+
+func TestElementRefs(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema xmlns:tns="http://example.com/tns">
+	<element name="comment" type="string" />
+	<element name="node">
+		<complexType>
+			<sequence>
+				<element ref="tns:comment" minOccurs="0" />
+				<element ref="node" minOccurs="0" maxOccurs="unbounded" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []*xmlTree{
+		{Name: "comment", Optional: true, Type: "string"},
+		{Name: "node", List: true, Optional: true, Ref: true},
+	}
+	if len(elems) != 2 || !reflect.DeepEqual(elems[1].Children, exp) {
+		t.Errorf("Unexpected XML elements")
+		pretty.Println(elems)
+	}
+
+	schema.Elements = schema.Elements[1:]
+	if _, err := newBuilder([]xsdSchema{schema}).buildXML(); err == nil || !strings.Contains(err.Error(), "tns:comment") {
+		t.Errorf("Expected an undefined ref error, got %v", err)
+	}
+}
+
+func TestSubstitutionGroups(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element ref="shape" maxOccurs="unbounded" />
+			</sequence>
+		</complexType>
+	</element>
+	<element name="shape" type="shapeType" abstract="true" />
+	<element name="circle" type="circleType" substitutionGroup="shape" />
+	<element name="square" substitutionGroup="shape" />
+	<complexType name="shapeType">
+		<attribute name="color" type="string" />
+	</complexType>
+	<complexType name="circleType">
+		<attribute name="radius" type="int" />
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	elems, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := elems[0].Children
+	if len(children) != 2 {
+		t.Fatalf("Unexpected children of drawing: %d", len(children))
+	}
+	for i, exp := range []struct{ name, typ string }{
+		{"circle", "circleType"},
+		{"square", "shapeType"},
+	} {
+		c := children[i]
+		if c.Name != exp.name || c.TypeName != exp.typ || !c.List || !c.Optional {
+			t.Errorf("Unexpected child %d of drawing", i)
+			pretty.Println(c)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	data := []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:x="urn:x">
+	<xs:annotation><xs:appinfo><x:note /></xs:appinfo></xs:annotation>
+	<xs:complexType name="itemType">
+		<xs:sequence>
+			<xs:element name="name" type="xs:string" />
+			<xs:any />
+		</xs:sequence>
+		<xs:attribute name="id" type="xs:int" />
+		<xs:anyAttribute />
+	</xs:complexType>
+	<xs:element name="item" type="itemType">
+		<xs:key name="itemKey"><xs:selector xpath="." /></xs:key>
+	</xs:element>
+</xs:schema>`)
+
+	reports, err := checkSchema("item.xsd", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		"item.xsd:6: unsupported any in complexType 'itemType'",
+	}
+	if !reflect.DeepEqual(reports, exp) {
+		t.Errorf("Unexpected reports")
+		pretty.Println(reports)
+	}
+}
+
+func TestTargetNamespace(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema targetNamespace="http://example.com/ns" xmlns:ns="http://example.com/ns">
+	<element name="note" type="string" />
+	<element name="memo">
+		<complexType>
+			<sequence>
+				<element ref="ns:note" />
+				<element name="author" type="string" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, roots[1:]); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type memo struct {
+	XMLName xml.Name ` + "`xml:\"http://example.com/ns memo\"`" + `
+	Note string ` + "`xml:\"http://example.com/ns note\"`" + `
+	Author string ` + "`xml:\"author\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+// runGenerated runs a program of the generated source src and the source of
+// its main package, and returns its output. It needs the go tool, so the
+// test is skipped without it.
+func runGenerated(t *testing.T, src, main string) string {
+	t.Helper()
+	return runFiles(t, map[string][]byte{"generated.go": []byte(src), "main.go": []byte(main)})
+}
+
+// runFiles runs a program of the given files of its main package, and
+// returns its output.
+func runFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("needs the go tool")
+	}
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module generated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var src []string
+		for name, content := range files {
+			src = append(src, name+":\n"+string(content))
+		}
+		sort.Strings(src)
+		t.Fatalf("%s\n%s\n%s", err, out, strings.Join(src, "\n"))
+	}
+	return string(out)
+}
+
+// TestRootRoundTrip runs the generated source with a program marshalling a
+// root element.
+func TestRootRoundTrip(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema targetNamespace="http://example.com/ns">
+	<element name="note">
+		<complexType>
+			<sequence>
+				<element name="to" type="string" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var src bytes.Buffer
+	if err := (generator{pkg: "main"}).do(&src, roots); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	var n note
+	if err := xml.Unmarshal([]byte("<note xmlns=\"http://example.com/ns\"><to>Bob</to></note>"), &n); err != nil {
+		panic(err)
+	}
+	out, err := xml.Marshal(n)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(string(out))
+}
+`)
+	if want := `<note xmlns="http://example.com/ns"><to>Bob</to></note>`; out != want {
+		t.Errorf("Round trip gave %s, want %s", out, want)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	// A tag key that Options.FieldTags rejects, ending the struct tags
+	root := &xmlTree{Name: "note", Type: "note", Children: []*xmlTree{{Name: "to", Type: "string"}}}
+	var out bytes.Buffer
+	err := (generator{pkg: "test", fieldTags: []string{"a`b"}}).do(&out, []*xmlTree{root})
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("Generating invalid source gave error %v, want one wrapping ErrFormat\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "type note struct") {
+		t.Errorf("Missing the unformatted source:\n%s", out.String())
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "note.xsd")
+	if err := ioutil.WriteFile(path, []byte(`<schema>
+	<element name="note">
+		<complexType>
+			<attribute name="to" type="string" use="required" />
+		</complexType>
+	</element>
+</schema>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Generate(&out, path, Options{Package: "notes", Exported: true, JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Code generated by goxsd from note.xsd; DO NOT EDIT.\n",
+		"package notes\n",
+		"type Note struct {\n",
+		"To      string   `xml:\"to,attr\" json:\"To\"`\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing %q in generated Go source", want)
+			t.Logf(out.String())
+		}
+	}
+
+	if err := Generate(&out, filepath.Join(dir, "missing.xsd"), Options{}); err == nil {
+		t.Errorf("Expected an error for a missing schema")
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.xsd"), []byte(`<schema><complexType name="b" /></schema>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative imports of a schema without location resolve against the
+	// working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	schemas, err := parseXSDReader(strings.NewReader(`<schema><import schemaLocation="b.xsd" /><complexType name="a" /></schema>`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range schemas {
+		for _, c := range s.ComplexTypes {
+			names = append(names, c.Name)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Unexpected complex types %q", names)
+	}
+	if schemas[0].loc != stdinLocation {
+		t.Errorf("Unexpected location %q", schemas[0].loc)
+	}
+}
+
+func TestStringTypes(t *testing.T) {
+	b := newBuilder(nil)
+	for _, name := range []string{
+		"string", "normalizedString", "token", "language", "anyURI",
+		"Name", "NCName", "QName", "NMTOKEN", "NMTOKENS", "NOTATION",
+		"ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES",
+	} {
+		got := b.findType("xsd:" + name)
+		if got != "string" {
+			t.Errorf("findType(%q) = %q, want %q", "xsd:"+name, got, "string")
+		}
+		if typ, ok := got.(string); !ok || !builtinType(typ) {
+			t.Errorf("findType(%q) is not a Go type", "xsd:"+name)
+		}
+	}
+}
+
+func TestAnyType(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="envelope">
+		<complexType>
+			<sequence>
+				<element name="header" type="xsd:anyType" />
+				<element name="body" />
+			</sequence>
+			<attribute name="version" type="string" />
+			<anyAttribute />
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type envelope struct {
+	XMLName xml.Name ` + "`xml:\"envelope\"`" + `
+	Version string ` + "`xml:\"version,attr,omitempty\"`" + `
+	AnyAttrs []xml.Attr ` + "`xml:\",any,attr\"`" + `
+	Header header ` + "`xml:\"header\"`" + `
+	Body body ` + "`xml:\"body\"`" + `
+}
+
+type header struct {
+	AnyAttrs []xml.Attr ` + "`xml:\",any,attr\"`" + `
+	InnerXML string ` + "`xml:\",innerxml\"`" + `
+}
+
+type body struct {
+	AnyAttrs []xml.Attr ` + "`xml:\",any,attr\"`" + `
+	InnerXML string ` + "`xml:\",innerxml\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+	if imps := generatedImports(t, generator{}, roots); !reflect.DeepEqual(imps, []string{"encoding/xml"}) {
+		t.Errorf("Unexpected imports %q", imps)
+	}
+}
+
+func TestLog(t *testing.T) {
+	schema := `<schema>
+	<complexType name="node">
+		<sequence>
+			<element name="child" type="node" minOccurs="0" />
+			<element name="price" type="money" />
+		</sequence>
+	</complexType>
+	<element name="tree" type="node" />
+</schema>`
+
+	var out, logged bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Log: &logged}); err != nil {
+		t.Fatal(err)
+	}
+	want := `element 'tree' in schema '<stdin>': type 'node' resolves to complexType 'node'
+element 'tree' in schema '<stdin>': building complexType 'node' of element 'tree'
+element 'tree' in schema '<stdin>': element 'child' refers to complexType 'node', which is being built, by pointer
+element 'tree' in schema '<stdin>': unresolved type 'money' of element 'price', using string
+`
+	if logged.String() != want {
+		t.Errorf("Unexpected log\n%s\nwant\n%s", logged.String(), want)
+	}
+
+	var quiet bytes.Buffer
+	if err := GenerateFrom(&quiet, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if quiet.String() != out.String() {
+		t.Errorf("Logging changed the generated code\n%s\nwant\n%s", out.String(), quiet.String())
+	}
+}
+
+func TestSimpleContentRestrictionAttributes(t *testing.T) {
+	schema := `<schema>
+	<complexType name="amount">
+		<simpleContent>
+			<extension base="decimal">
+				<attribute name="currency" type="string" />
+				<attribute name="rate" type="decimal" />
+			</extension>
+		</simpleContent>
+	</complexType>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="total">
+					<complexType>
+						<simpleContent>
+							<restriction base="amount">
+								<attribute name="currency" type="string" use="required" />
+								<attribute name="rate" use="prohibited" />
+								<attribute name="rounded" type="boolean" />
+							</restriction>
+						</simpleContent>
+					</complexType>
+				</element>
+				<element name="code">
+					<complexType>
+						<simpleContent>
+							<restriction base="string">
+								<attribute name="scheme" type="string" />
+							</restriction>
+						</simpleContent>
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{ChardataName: "Value"}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type total struct { Currency string `xml:\"currency,attr\"` Rounded bool `xml:\"rounded,attr,omitempty\"` Value float64 `xml:\",chardata\"` }",
+		"type code struct { Scheme string `xml:\"scheme,attr,omitempty\"` Value string `xml:\",chardata\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestAppInfoDirectives(t *testing.T) {
+	schema := `<schema xmlns:jaxb="http://java.sun.com/xml/ns/jaxb">
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="total">
+					<annotation>
+						<documentation>The total price.</documentation>
+						<appinfo>goxsd:type=github.com/shopspring/decimal.Decimal</appinfo>
+					</annotation>
+					<complexType>
+						<simpleContent>
+							<extension base="decimal">
+								<attribute name="currency" type="string" />
+							</extension>
+						</simpleContent>
+					</complexType>
+				</element>
+				<element name="ref_no" type="string">
+					<annotation>
+						<appinfo>
+							<jaxb:property name="reference" />
+							goxsd:name=Reference
+						</appinfo>
+					</annotation>
+				</element>
+				<element name="placed" type="string">
+					<annotation>
+						<appinfo>goxsd:type=time.Time goxsd:name=PlacedAt</appinfo>
+					</annotation>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		`import ( "encoding/xml" "time" "github.com/shopspring/decimal" )`,
+		"// The total price. Total decimal.Decimal `xml:\"total\"`",
+		"Reference string `xml:\"ref_no\"`",
+		"PlacedAt time.Time `xml:\"placed\"`",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if strings.Contains(s, "Currency") {
+		t.Error("Unexpected struct of the type replaced by goxsd:type")
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	for directive, want := range map[string]string{
+		"goxsd:size=10":                 "unknown directive goxsd:size",
+		"goxsd:name":                    "directive goxsd:name has no value",
+		"goxsd:name=amount":             "directive goxsd:name: amount is not an exported Go identifier",
+		"goxsd:type=Decimal":            "directive goxsd:type: Decimal is not a predeclared Go type",
+		"goxsd:type=example.com/x.type": "directive goxsd:type: example.com/x.type is not an exported Go type",
+	} {
+		schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="total" type="string">
+					<annotation><appinfo>` + directive + `</appinfo></annotation>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+		err := GenerateFrom(ioutil.Discard, strings.NewReader(schema), Options{})
+		if err == nil || !strings.Contains(err.Error(), "while building element 'order'") || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error containing %q for %s, got %v", want, directive, err)
+		}
+	}
+}
+
+func TestElementTypeAndInlineType(t *testing.T) {
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="status" type="string">
+					<simpleType>
+						<restriction base="string">
+							<enumeration value="open" />
+							<enumeration value="closed" />
+						</restriction>
+					</simpleType>
+				</element>
+				<element name="total" type="string">
+					<complexType>
+						<simpleContent>
+							<extension base="decimal">
+								<attribute name="currency" type="string" />
+							</extension>
+						</simpleContent>
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"Status status `xml:\"status\"`",
+		"type status string",
+		"statusOpen status = \"open\"",
+		"Total total `xml:\"total\"`",
+		"Currency string `xml:\"currency,attr,omitempty\"`",
+		"Total float64 `xml:\",chardata\"`",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestFieldTags(t *testing.T) {
+	schema := `<schema>
+	<element name="user">
+		<complexType>
+			<sequence>
+				<element name="name" type="string" />
+			</sequence>
+			<attribute name="id" type="string" />
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{FieldTags: []string{"form", "yaml"}, JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"XMLName xml.Name `xml:\"user\" form:\"user\" yaml:\"user\" json:\"-\"`",
+		"ID string `xml:\"id,attr,omitempty\" form:\"id,attr,omitempty\" yaml:\"id,attr,omitempty\" json:\"ID,omitempty\"`",
+		"Name string `xml:\"name\" form:\"name\" yaml:\"name\" json:\"Name\"`",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	for _, key := range []string{"xml", "json", "", "a b", "a:b", `a"b`} {
+		if err := GenerateFrom(ioutil.Discard, strings.NewReader(schema), Options{FieldTags: []string{key}}); err == nil {
+			t.Errorf("Expected an error for the field tag %q", key)
+		}
+	}
+}
+
+func TestSchemaPrefixes(t *testing.T) {
+	// The schema with the XSD namespace bound to the prefix P, as in
+	// "P:element", or the default namespace for an empty prefix
+	schema := func(prefix string) string {
+		s := `<P:schema xmlns:P="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:pets" targetNamespace="urn:pets">
+	<P:simpleType name="kind">
+		<P:restriction base="P:string">
+			<P:enumeration value="cat" />
+			<P:enumeration value="dog" />
+		</P:restriction>
+	</P:simpleType>
+	<P:complexType name="pet">
+		<P:sequence>
+			<P:element name="name" type="P:string" />
+			<P:element name="age" type="P:int" minOccurs="0" />
+			<P:element name="kind" type="tns:kind" />
+			<P:element name="vaccinated" type="P:boolean" />
+		</P:sequence>
+		<P:attribute name="born" type="P:date" />
+	</P:complexType>
+	<P:element name="pets">
+		<P:complexType>
+			<P:sequence>
+				<P:element name="pet" type="tns:pet" maxOccurs="unbounded" />
+			</P:sequence>
+		</P:complexType>
+	</P:element>
+</P:schema>`
+		if prefix == "" {
+			s = strings.Replace(s, "xmlns:P=", "xmlns=", 1)
+			return strings.Replace(s, "P:", "", -1)
+		}
+		return strings.Replace(s, "P:", prefix+":", -1)
+	}
+
+	var want bytes.Buffer
+	if err := GenerateFrom(&want, strings.NewReader(schema("xsd")), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"Pet []pet `xml:\"pet\"`",
+		"Name string `xml:\"name\"`",
+		"Age *int32 `xml:\"age,omitempty\"`",
+		"Kind kind `xml:\"kind\"`",
+		"Vaccinated bool `xml:\"vaccinated\"`",
+		"Born xsdDate `xml:\"born,attr,omitempty\"`",
+		"type kind string",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(want.String()), " "), exp) {
+			t.Errorf("Missing %q in the generated code\n%s", exp, want.String())
+		}
+	}
+
+	for _, prefix := range []string{"xs", "", "schema"} {
+		var out bytes.Buffer
+		if err := GenerateFrom(&out, strings.NewReader(schema(prefix)), Options{}); err != nil {
+			t.Errorf("prefix %q: %s", prefix, err)
+			continue
+		}
+		if out.String() != want.String() {
+			t.Errorf("prefix %q gave\n%s\nwant\n%s", prefix, out.String(), want.String())
+		}
+		reports, err := CheckFrom(strings.NewReader(schema(prefix)), Options{})
+		if err != nil || len(reports) > 0 {
+			t.Errorf("prefix %q: unexpected reports %q, %v", prefix, reports, err)
+		}
+	}
+}
+
+func TestIsList(t *testing.T) {
+	for _, tt := range []struct {
+		max  string
+		want bool
+	}{
+		{"", false},
+		{"0", false},
+		{"1", false},
+		{"3", true},
+		{"unbounded", true},
+	} {
+		if got := (xsdElement{Max: tt.max}).isList(); got != tt.want {
+			t.Errorf("isList() with maxOccurs %q = %t, want %t", tt.max, got, tt.want)
+		}
+	}
+
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="hand">
+		<complexType>
+			<sequence>
+				<element name="card" type="string" maxOccurs="3" />
+				<element name="owner" type="string" maxOccurs="1" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, roots); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Card    []string `xml:\"card\"`\n", "Owner   string   `xml:\"owner\"`\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing %q in generated Go source", want)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestSimpleContentChain(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="price" type="priceType" />
+	<complexType name="amountType">
+		<simpleContent>
+			<extension base="decimal" />
+		</simpleContent>
+	</complexType>
+	<complexType name="priceType">
+		<simpleContent>
+			<extension base="amountType">
+				<attribute name="currency" type="string" use="required" />
+			</extension>
+		</simpleContent>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := (generator{cdataName: "Value"}).do(&out, roots[:1]); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type priceType struct {
+	XMLName xml.Name ` + "`xml:\"price\"`" + `
+	Currency string ` + "`xml:\"currency,attr\"`" + `
+	Value float64 ` + "`xml:\",chardata\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+func TestRestrictionChain(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="item">
+		<complexType>
+			<attribute name="size" type="smallSize" />
+			<attribute name="code" type="codeType" />
+			<attribute name="odd" type="oddType" />
+		</complexType>
+	</element>
+	<simpleType name="size">
+		<restriction base="xsd:int" />
+	</simpleType>
+	<simpleType name="smallSize">
+		<restriction base="size">
+			<maxInclusive value="10" />
+		</restriction>
+	</simpleType>
+	<simpleType name="codeType">
+		<restriction base="codeType" />
+	</simpleType>
+	<complexType name="complexBase" />
+	<simpleType name="oddType">
+		<restriction base="complexBase" />
+	</simpleType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []xmlAttrib{
+		{Name: "size", Type: "int32", Optional: true},
+		{Name: "code", Type: "string", Optional: true},
+		{Name: "odd", Type: "string", Optional: true},
+	}
+	if !reflect.DeepEqual(roots[0].Attribs, exp) {
+		t.Errorf("Unexpected attributes")
+		pretty.Println(roots[0].Attribs)
+	}
+}
+
+func TestIndent(t *testing.T) {
+	root := &xmlTree{
+		Name:    "note",
+		Type:    "note",
+		Attribs: []xmlAttrib{{Name: "to", Type: "string"}},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{pkg: "test", indent: 2}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n  To string `xml:\"to,attr\"`\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("Missing %q in generated Go source", want)
+		t.Logf(out.String())
+	}
+
+	out.Reset()
+	if err := (generator{pkg: "test"}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n\tTo string `xml:\"to,attr\"`\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("Missing %q in generated Go source", want)
+		t.Logf(out.String())
+	}
+}
+
+func TestFieldNameCollisions(t *testing.T) {
+	root := &xmlTree{
+		Name: "record",
+		Type: "record",
+		Attribs: []xmlAttrib{
+			{Name: "id", Type: "string"},
+			{Name: "value", Type: "string"},
+		},
+		Children: []*xmlTree{
+			{Name: "id", Type: "int"},
+			{Name: "foo-bar", Type: "string"},
+			{Name: "fooBar", Type: "string"},
+		},
+	}
+	other := &xmlTree{
+		Name:    "amount",
+		Type:    "float64",
+		Cdata:   true,
+		Attribs: []xmlAttrib{{Name: "value", Type: "string"}},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{cdataName: "Value", json: true}).do(&out, []*xmlTree{root, other}); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type record struct {
+	IDAttr string ` + "`xml:\"id,attr\" json:\"IDAttr\"`" + `
+	Value string ` + "`xml:\"value,attr\" json:\"Value\"`" + `
+	ID int ` + "`xml:\"id\" json:\"ID\"`" + `
+	FooBar string ` + "`xml:\"foo-bar\" json:\"FooBar\"`" + `
+	FooBar2 string ` + "`xml:\"fooBar\" json:\"FooBar2\"`" + `
+}
+
+type amount struct {
+	ValueAttr string ` + "`xml:\"value,attr\" json:\"ValueAttr\"`" + `
+	Value float64 ` + "`xml:\",chardata\" json:\"Value\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source")
+		t.Logf(out.String())
+	}
+}
+
+func TestAllowedValuesDoc(t *testing.T) {
+	root := &xmlTree{
+		Name:    "ticket",
+		Type:    "ticket",
+		Attribs: []xmlAttrib{{Name: "status", Type: "string", Enums: []string{"open", "closed", "pending"}}},
+		Children: []*xmlTree{
+			{Name: "urgent", Type: "bool", Enums: []string{"true"}},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (generator{}).do(&out, []*xmlTree{root}); err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []string{
+		"// Allowed values: open, closed, pending.\n",
+		"// Allowed values: true.\n",
+	} {
+		if !strings.Contains(out.String(), note) {
+			t.Errorf("Generated Go source lacks %q", note)
+		}
+	}
+	if strings.Contains(out.String(), "const") {
+		t.Errorf("Unexpected constants for enumerations of a non-constant type")
+		t.Logf(out.String())
+	}
+}
+
+func TestDuplicateRoots(t *testing.T) {
+	var schemas []xsdSchema
+	for _, src := range []string{
+		`<schema targetNamespace="urn:a"><element name="note" type="string" /><element name="memo" type="string" /></schema>`,
+		`<schema targetNamespace="urn:a"><element name="note" type="string" /></schema>`,
+		`<schema targetNamespace="urn:b"><element name="note" type="string" /></schema>`,
+	} {
+		var s xsdSchema
+		if err := xml.Unmarshal([]byte(src), &s); err != nil {
+			t.Fatal(err)
+		}
+		schemas = append(schemas, s)
+	}
+
+	roots, err := newBuilder(schemas).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range roots {
+		names = append(names, e.Namespace+" "+e.Name)
+	}
+	exp := []string{"urn:a note", "urn:a memo", "urn:b note"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Errorf("Unexpected roots %q, want %q", names, exp)
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	schema := `<schema>
+	<element name="order" type="orderType" />
+	<complexType name="orderType">
+		<sequence>
+			<group ref="lines" />
+			<element name="note" type="noteType" />
+		</sequence>
+		<attributeGroup ref="audit" />
+	</complexType>
+	<group name="lines">
+		<sequence>
+			<element name="line" type="lineType" maxOccurs="unbounded" />
+		</sequence>
+	</group>
+	<attributeGroup name="audit">
+		<attribute name="created" type="dateTime" />
+		<attribute name="author" type="string" />
+	</attributeGroup>
+	<complexType name="lineType"><attribute name="sku" type="string" /></complexType>
+	<complexType name="unusedA"><attribute name="a" type="string" /></complexType>
+	<complexType name="unusedB"><attribute name="b" type="string" /></complexType>
+	<complexType name="unusedC"><attribute name="c" type="string" /></complexType>
+	<simpleType name="noteType">
+		<restriction base="string">
+			<enumeration value="x" />
+			<enumeration value="y" />
+		</restriction>
+	</simpleType>
+</schema>`
+
+	var first string
+	for i := 0; i < 20; i++ {
+		var out bytes.Buffer
+		if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "p", Exported: true}); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = out.String()
+		} else if out.String() != first {
+			t.Fatalf("Generated Go source differs between runs:\n%s\n---\n%s", first, out.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := `<schema>
+	<element name="book">
+		<complexType>
+			<sequence>
+				<element name="isbn" type="isbnType" />
+				<element name="title" type="titleType" />
+				<element name="subtitle" type="titleType" minOccurs="0" />
+				<element name="tag" type="tagType" maxOccurs="unbounded" />
+				<element name="code" type="codeType" />
+			</sequence>
+			<attribute name="lang" type="langType" />
+		</complexType>
+	</element>
+	<complexType name="codeType">
+		<simpleContent>
+			<extension base="shortCode">
+				<attribute name="scheme" type="string" />
+			</extension>
+		</simpleContent>
+	</complexType>
+	<simpleType name="isbnType">
+		<restriction base="string">
+			<pattern value="\d{3}-\d{10}" />
+		</restriction>
+	</simpleType>
+	<simpleType name="titleType">
+		<restriction base="string">
+			<minLength value="1" />
+			<maxLength value="10" />
+		</restriction>
+	</simpleType>
+	<simpleType name="tagType">
+		<restriction base="string">
+			<maxLength value="3" />
+		</restriction>
+	</simpleType>
+	<simpleType name="shortCode">
+		<restriction base="code">
+			<length value="2" />
+		</restriction>
+	</simpleType>
+	<simpleType name="code">
+		<restriction base="string">
+			<pattern value="[A-Z]+" />
+			<length value="5" />
+		</restriction>
+	</simpleType>
+	<simpleType name="langType">
+		<restriction base="string">
+			<pattern value="[a-z]{2}" />
+		</restriction>
+	</simpleType>
+</schema>`
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", Validate: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	for _, doc := range []string{
+		"<book><isbn>978-0123456789</isbn><title>Go</title><tag>a</tag><code>AB</code></book>",
+		"<book lang=\"en\"><isbn>978-0123456789</isbn><title>Go</title><subtitle>A tour</subtitle><code>AB</code></book>",
+		"<book><isbn>0123456789</isbn><title>Go</title><code>AB</code></book>",
+		"<book lang=\"english\"><isbn>978-0123456789</isbn><title>Go</title><code>AB</code></book>",
+		"<book><isbn>978-0123456789</isbn><title></title><code>AB</code></book>",
+		"<book><isbn>978-0123456789</isbn><title>Go</title><subtitle>A long journey</subtitle><code>AB</code></book>",
+		"<book><isbn>978-0123456789</isbn><title>Go</title><tag>abcd</tag><code>AB</code></book>",
+		"<book><isbn>978-0123456789</isbn><title>Go</title><code>ABC</code></book>",
+	} {
+		var b book
+		if err := xml.Unmarshal([]byte(doc), &b); err != nil {
+			panic(err)
+		}
+		err := b.Validate()
+		if err == nil {
+			err = b.Code.Validate()
+		}
+		fmt.Println(err)
+	}
+}
+`)
+	want := `<nil>
+<nil>
+isbn: "0123456789" does not match the pattern \d{3}-\d{10}
+lang: "english" does not match the pattern [a-z]{2}
+title: length 0 is not between 1 and 10
+subtitle: length 14 is not between 1 and 10
+tag: length 4 is more than 3
+code: length 3 is not 2
+`
+	if out != want {
+		t.Errorf("Validation gave\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestRedefine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"person.xsd": `<schema>
+	<complexType name="personType">
+		<sequence>
+			<element name="name" type="string" />
+		</sequence>
+		<attributeGroup ref="audit" />
+	</complexType>
+	<simpleType name="codeType">
+		<restriction base="string">
+			<maxLength value="10" />
+		</restriction>
+	</simpleType>
+	<attributeGroup name="audit">
+		<attribute name="created" type="string" />
+	</attributeGroup>
+</schema>`,
+		"main.xsd": `<schema>
+	<redefine schemaLocation="person.xsd">
+		<complexType name="personType">
+			<complexContent>
+				<extension base="personType">
+					<sequence>
+						<element name="email" type="string" />
+					</sequence>
+				</extension>
+			</complexContent>
+		</complexType>
+		<simpleType name="codeType">
+			<restriction base="codeType">
+				<pattern value="[A-Z]+" />
+			</restriction>
+		</simpleType>
+		<attributeGroup name="audit">
+			<attributeGroup ref="audit" />
+			<attribute name="modified" type="string" />
+		</attributeGroup>
+	</redefine>
+	<element name="person" type="personType" />
+	<element name="code" type="codeType" />
+</schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder(schemas).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 {
+		t.Fatalf("Got %d roots, want 2", len(roots))
+	}
+
+	person := roots[0]
+	var children, attrs []string
+	for _, c := range person.Children {
+		children = append(children, c.Name)
+	}
+	for _, a := range person.Attribs {
+		attrs = append(attrs, a.Name)
+	}
+	if exp := []string{"name", "email"}; !reflect.DeepEqual(children, exp) {
+		t.Errorf("Unexpected children %q of the redefined type, want %q", children, exp)
+	}
+	if exp := []string{"modified", "created"}; !reflect.DeepEqual(attrs, exp) {
+		t.Errorf("Unexpected attributes %q of the redefined type, want %q", attrs, exp)
+	}
+
+	exp := &xmlFacets{Patterns: []string{"[A-Z]+"}, MaxLength: 10}
+	if code := roots[1]; !reflect.DeepEqual(code.Facets, exp) {
+		t.Errorf("Unexpected facets %s of the redefined simple type, want %s", code.Facets, exp)
+	}
+}
+
+func TestQualifiedTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"a.xsd": `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:a" xmlns:a="urn:a">
+	<xs:complexType name="addressType">
+		<xs:sequence>
+			<xs:element name="street" type="xs:string" />
+		</xs:sequence>
+	</xs:complexType>
+</xs:schema>`,
+		"b.xsd": `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:b" xmlns="urn:b">
+	<xs:complexType name="addressType">
+		<xs:sequence>
+			<xs:element name="line" type="xs:string" maxOccurs="unbounded" />
+		</xs:sequence>
+	</xs:complexType>
+	<xs:element name="office" type="addressType" />
+</xs:schema>`,
+		"main.xsd": `<schema xmlns:a="urn:a" xmlns:b="urn:b">
+	<import namespace="urn:a" schemaLocation="a.xsd" />
+	<import namespace="urn:b" schemaLocation="b.xsd" />
+	<element name="home" type="a:addressType" />
+	<element name="work" type="b:addressType" />
+</schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder(schemas).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := make(map[string]string)
+	for _, e := range roots {
+		if len(e.Children) != 1 {
+			t.Fatalf("Got %d children of %s, want 1", len(e.Children), e.Name)
+		}
+		types[e.Name] = e.TypeName + " " + e.Children[0].Name
+	}
+	exp := map[string]string{
+		"home":   "addressType street",
+		"work":   "addressType2 line",
+		"office": "addressType2 line",
+	}
+	if !reflect.DeepEqual(types, exp) {
+		t.Errorf("Unexpected types %v of the qualified references, want %v", types, exp)
+	}
+
+	var out bytes.Buffer
+	if err := Generate(&out, filepath.Join(dir, "main.xsd"), Options{Package: "test", Exported: true, NoNetwork: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"type AddressType struct", "type AddressType2 struct"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Missing %q in the generated code", s)
+			t.Logf(out.String())
+		}
+	}
+}
+
+func TestQualifiedRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"a.xsd": `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:a">
+	<xs:element name="item" type="xs:int" />
+</xs:schema>`,
+		"b.xsd": `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:b" xmlns:a="urn:a">
+	<xs:element name="item" type="xs:string" />
+	<xs:element name="extra" substitutionGroup="a:item" />
+</xs:schema>`,
+		"main.xsd": `<schema xmlns:a="urn:a" xmlns:b="urn:b">
+	<import namespace="urn:a" schemaLocation="a.xsd" />
+	<import namespace="urn:b" schemaLocation="b.xsd" />
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element ref="a:item" />
+				<element ref="b:item" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := parseXSDFile(filepath.Join(dir, "main.xsd"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder(schemas).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var children []string
+	for _, e := range roots {
+		if e.Name != "order" {
+			continue
+		}
+		for _, c := range e.Children {
+			children = append(children, c.Namespace+" "+c.Name+" "+c.Type)
+		}
+	}
+	exp := []string{"urn:a item int32", "urn:b extra int32", "urn:b item string"}
+	if !reflect.DeepEqual(children, exp) {
+		t.Errorf("Unexpected children %q of the qualified refs, want %q", children, exp)
+	}
+}
+
+func TestPointers(t *testing.T) {
+	root := &xmlTree{
+		Name:    "order",
+		Type:    "order",
+		Attribs: []xmlAttrib{{Name: "id", Type: "string", Optional: true}},
+		Children: []*xmlTree{
+			{Name: "note", Type: "string", Optional: true},
+			{Name: "total", Type: "float64"},
+			{Name: "line", Type: "string", List: true},
+			{Name: "parent", Type: "order", Ref: true},
+		},
+	}
+
+	tests := []struct {
+		pointers string
+		want     string
+	}{
+		{"", `
+type order struct {
+	ID string ` + "`xml:\"id,attr,omitempty\"`" + `
+	Note *string ` + "`xml:\"note,omitempty\"`" + `
+	Total float64 ` + "`xml:\"total\"`" + `
+	Line []string ` + "`xml:\"line\"`" + `
+	Parent *order ` + "`xml:\"parent\"`" + `
+}`},
+		{PointersAll, `
+type order struct {
+	ID *string ` + "`xml:\"id,attr,omitempty\"`" + `
+	Note *string ` + "`xml:\"note,omitempty\"`" + `
+	Total *float64 ` + "`xml:\"total\"`" + `
+	Line []string ` + "`xml:\"line\"`" + `
+	Parent *order ` + "`xml:\"parent\"`" + `
+}`},
+		{PointersNone, `
+type order struct {
+	ID string ` + "`xml:\"id,attr,omitempty\"`" + `
+	Note string ` + "`xml:\"note,omitempty\"`" + `
+	Total float64 ` + "`xml:\"total\"`" + `
+	Line []string ` + "`xml:\"line\"`" + `
+	Parent *order ` + "`xml:\"parent\"`" + `
+}`},
+	}
+	for _, tst := range tests {
+		var out bytes.Buffer
+		if err := (generator{pointers: tst.pointers}).do(&out, []*xmlTree{root}); err != nil {
+			t.Fatal(err)
+		}
+		out = removeComments(out)
+		if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(tst.want), "") {
+			t.Errorf("Unexpected generated Go source with pointer mode %q", tst.pointers)
+			t.Logf(out.String())
+		}
+	}
+
+	if err := GenerateFrom(ioutil.Discard, strings.NewReader("<schema />"), Options{Pointers: "some"}); err == nil {
+		t.Errorf("Expected an error for an unknown pointer mode")
+	}
+}
+
+func TestAbstractComplexType(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<complexType name="shapeType" abstract="true">
+		<sequence>
+			<element name="color" type="string" />
+		</sequence>
+	</complexType>
+	<complexType name="circleType">
+		<complexContent>
+			<extension base="shapeType">
+				<sequence>
+					<element name="radius" type="double" />
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="squareType">
+		<complexContent>
+			<extension base="shapeType">
+				<sequence>
+					<element name="side" type="double" />
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range roots {
+		var fields []string
+		for _, c := range e.Children {
+			fields = append(fields, c.Name)
+		}
+		types = append(types, structName(e)+" "+strings.Join(fields, ","))
+	}
+	exp := []string{"circleType color,radius", "squareType color,side"}
+	if !reflect.DeepEqual(types, exp) {
+		t.Errorf("Unexpected types %q, want %q", types, exp)
+	}
+}
+
+func TestAttributeRefs(t *testing.T) {
+	schema := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:shop" targetNamespace="urn:shop"
+	xmlns:x="http://www.w3.org/XML/1998/namespace">
+	<xs:attribute name="currency" type="xs:string">
+		<xs:annotation><xs:documentation>ISO 4217 code</xs:documentation></xs:annotation>
+	</xs:attribute>
+	<xs:attribute name="amount" type="xs:decimal" />
+	<xs:complexType name="price">
+		<xs:attribute ref="tns:currency" use="required" />
+		<xs:attribute ref="tns:amount" />
+	</xs:complexType>
+	<xs:complexType name="fee">
+		<xs:attribute ref="tns:currency" default="EUR" />
+		<xs:attribute ref="xml:lang" />
+		<xs:attribute ref="x:space" />
+	</xs:complexType>
+</xs:schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type price struct { // ISO 4217 code Currency string `xml:\"currency,attr\"` Amount float64 `xml:\"amount,attr,omitempty\"` }",
+		"type fee struct { // ISO 4217 code // Defaults to \"EUR\" when absent. Currency string `xml:\"currency,attr,omitempty\"` Lang string `xml:\"lang,attr,omitempty\"` Space string `xml:\"space,attr,omitempty\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	undefined := strings.Replace(schema, `ref="tns:amount"`, `ref="tns:total"`, 1)
+	err := GenerateFrom(ioutil.Discard, strings.NewReader(undefined), Options{})
+	want := "while building complexType 'price' in schema '<stdin>': undefined attribute ref: {urn:shop}total"
+	if err == nil || err.Error() != want {
+		t.Errorf("Unexpected error %v, want %s", err, want)
+	}
+}
+
+func TestAbstractElementType(t *testing.T) {
+	schema := `<schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<complexType name="shapeType" abstract="true">
+		<sequence>
+			<element name="color" type="string" />
+		</sequence>
+	</complexType>
+	<complexType name="circleType">
+		<complexContent>
+			<extension base="shapeType">
+				<sequence>
+					<element name="radius" type="double" />
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="squareType">
+		<complexContent>
+			<extension base="shapeType">
+				<sequence>
+					<element name="side" type="double" />
+				</sequence>
+			</extension>
+		</complexContent>
+	</complexType>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="shape" type="shapeType" maxOccurs="unbounded" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type drawing struct { XMLName xml.Name `xml:\"drawing\"` // The type shapeType is abstract: the concrete type of the element is named by // its xsi:type attribute, one of circleType, squareType. Only the fields of // shapeType are decoded. Shape []shapeType `xml:\"shape\"` }",
+		"type shapeType struct { Color string `xml:\"color\"` }",
+		"type circleType struct {",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestExtensionChain(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="manager" type="managerType" />
+	<element name="price" type="discountPrice" />
+	<complexType name="entityType">
+		<sequence>
+			<element name="id" type="int" />
+		</sequence>
+		<attribute name="created" type="dateTime" />
+	</complexType>
+	<complexType name="personType">
+		<complexContent>
+			<extension base="entityType">
+				<sequence>
+					<element name="name" type="string" />
+				</sequence>
+				<attribute name="title" type="string" />
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="managerType">
+		<complexContent>
+			<extension base="personType">
+				<sequence>
+					<element name="reports" type="int" />
+				</sequence>
+				<attribute name="level" type="int" />
+			</extension>
+		</complexContent>
+	</complexType>
+	<complexType name="amountType">
+		<simpleContent>
+			<extension base="decimal">
+				<attribute name="currency" type="string" />
+			</extension>
+		</simpleContent>
+	</complexType>
+	<complexType name="priceType">
+		<simpleContent>
+			<extension base="amountType">
+				<attribute name="vat" type="boolean" />
+			</extension>
+		</simpleContent>
+	</complexType>
+	<complexType name="discountPrice">
+		<simpleContent>
+			<extension base="priceType">
+				<attribute name="percent" type="int" />
+			</extension>
+		</simpleContent>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := func(e *xmlTree) []string {
+		var m []string
+		for _, a := range e.Attribs {
+			m = append(m, "@"+a.Name)
+		}
+		for _, c := range e.Children {
+			m = append(m, c.Name)
+		}
+		return m
+	}
+
+	manager := roots[0]
+	if exp := []string{"@created", "@title", "@level", "id", "name", "reports"}; !reflect.DeepEqual(members(manager), exp) {
+		t.Errorf("Unexpected members %q of managerType, want %q", members(manager), exp)
+	}
+
+	price := roots[1]
+	if exp := []string{"@currency", "@vat", "@percent"}; !reflect.DeepEqual(members(price), exp) {
+		t.Errorf("Unexpected members %q of discountPrice, want %q", members(price), exp)
+	}
+	if !price.Cdata || price.Type != "float64" {
+		t.Errorf("Got chardata %t of type %s for discountPrice, want float64 chardata", price.Cdata, price.Type)
+	}
+}
+
+func TestComplexRestriction(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="summary" type="summaryType" />
+	<element name="plain" type="plainType" />
+	<complexType name="reportType">
+		<sequence>
+			<element name="title" type="string" />
+			<element name="body" type="string" minOccurs="0" />
+			<element name="appendix" type="string" minOccurs="0" />
+		</sequence>
+		<attribute name="author" type="string" />
+		<attribute name="draft" type="boolean" />
+		<attribute name="pages" type="int" />
+	</complexType>
+	<complexType name="summaryType">
+		<complexContent>
+			<restriction base="reportType">
+				<sequence>
+					<element name="title" type="string" />
+				</sequence>
+				<attribute name="draft" type="boolean" use="prohibited" />
+				<attribute name="pages" type="int" use="required" />
+			</restriction>
+		</complexContent>
+	</complexType>
+	<complexType name="plainType">
+		<complexContent>
+			<restriction base="anyType">
+				<attribute name="lang" type="string" />
+			</restriction>
+		</complexContent>
+	</complexType>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := roots[0]
+	if len(summary.Children) != 1 || summary.Children[0].Name != "title" {
+		t.Errorf("Unexpected children of the restricted type")
+		pretty.Println(summary.Children)
+	}
+	exp := []xmlAttrib{
+		{Name: "author", Type: "string", Optional: true},
+		{Name: "pages", Type: "int32"},
+	}
+	if !reflect.DeepEqual(summary.Attribs, exp) {
+		t.Errorf("Unexpected attributes of the restricted type")
+		pretty.Println(summary.Attribs)
+	}
+
+	plain := roots[1]
+	if exp := []xmlAttrib{{Name: "lang", Type: "string", Optional: true}}; !reflect.DeepEqual(plain.Attribs, exp) || len(plain.Children) > 0 {
+		t.Errorf("Unexpected members of a restriction of anyType")
+		pretty.Println(plain)
+	}
+}
+
+func TestUnresolvedTypes(t *testing.T) {
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="customer" type="customerType" />
+				<element name="total" type="amountType" />
+			</sequence>
+			<attribute name="status" type="tns:statusType" />
+			<attribute name="ref" type="customerType" />
+		</complexType>
+	</element>
+	<simpleType name="amountType">
+		<restriction base="money" />
+	</simpleType>
+</schema>`
+
+	var out, warnings bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Warnings: &warnings}); err != nil {
+		t.Fatal(err)
+	}
+	want := `warning: unresolved type 'customerType' of element 'customer', attribute 'ref', using string
+warning: unresolved type 'money' of simpleType 'amountType', using string
+warning: unresolved type 'statusType' of attribute 'status', using string
+4 unresolved type references
+`
+	if warnings.String() != want {
+		t.Errorf("Unexpected warnings\n%s\nwant\n%s", warnings.String(), want)
+	}
+	for _, field := range []string{"Customer string", "Total string", "Status string", "Ref string"} {
+		if !strings.Contains(strings.Join(strings.Fields(out.String()), " "), field) {
+			t.Errorf("Missing field %q in generated Go source", field)
+			t.Logf(out.String())
+		}
+	}
+
+	err := GenerateFrom(ioutil.Discard, strings.NewReader(schema), Options{Strict: true})
+	want = "while building element 'order' in schema '<stdin>': unresolved type 'customerType' of element 'customer' (1 of 4 unresolved type references)"
+	if err == nil || err.Error() != want {
+		t.Errorf("Got error %v in strict mode, want %s", err, want)
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"orders.xsd": `<schema>
+	<include schemaLocation="common.xsd" />
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element ref="customer" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`,
+		"common.xsd": `<schema>
+	<complexType name="money">
+		<sequence>
+			<element name="amount" type="decimal" />
+		</sequence>
+	</complexType>
+</schema>`,
+		"broken.xsd": `<schema><include schemaLocation="missing.xsd" /></schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = Generate(ioutil.Discard, filepath.Join(dir, "orders.xsd"), Options{})
+	want := "while building element 'order' in schema 'orders.xsd': undefined element ref: customer"
+	if err == nil || err.Error() != want {
+		t.Errorf("Got error %v, want %s", err, want)
+	}
+
+	err = Generate(ioutil.Discard, filepath.Join(dir, "broken.xsd"), Options{})
+	if err == nil || !strings.HasPrefix(err.Error(), "importing 'missing.xsd' from schema 'broken.xsd': ") {
+		t.Errorf("Got error %v, want one naming the import", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Error %v does not wrap a not exist error", err)
+	}
+}
+
+func TestMissingBase(t *testing.T) {
+	schema := `<schema>
+	<element name="price">
+		<complexType>
+			<simpleContent>
+				<extension>
+					<attribute name="currency" type="string" />
+				</extension>
+			</simpleContent>
+		</complexType>
+	</element>
+	<element name="weight">
+		<complexType>
+			<simpleContent>
+				<restriction />
+			</simpleContent>
+		</complexType>
+	</element>
+</schema>`
+
+	var out, warnings bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Warnings: &warnings, ChardataName: "Value"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `warning: missing base type of the extension of element 'price', the restriction of element 'weight', using string
+2 unresolved type references
+`
+	if warnings.String() != want {
+		t.Errorf("Unexpected warnings\n%s\nwant\n%s", warnings.String(), want)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"Currency string `xml:\"currency,attr,omitempty\"` Value string `xml:\",chardata\"`",
+		"type weight struct { XMLName xml.Name `xml:\"weight\"` Value string `xml:\",chardata\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestNillable(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="person">
+		<complexType>
+			<sequence>
+				<element name="name" type="string" />
+				<element name="birthday" type="date" nillable="true" minOccurs="1" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := roots[0].Children; c[0].Nillable || !c[1].Nillable || c[1].Optional {
+		t.Errorf("Unexpected nillable children")
+		pretty.Println(c)
+	}
+
+	for _, pointers := range []string{"", PointersNone} {
+		var out bytes.Buffer
+		if err := (generator{pointers: pointers}).do(&out, roots); err != nil {
+			t.Fatal(err)
+		}
+		src := strings.Join(strings.Fields(out.String()), " ")
+		for _, field := range []string{"Name string `xml:\"name\"`", "Birthday *xsdDate `xml:\"birthday\"`"} {
+			if !strings.Contains(src, field) {
+				t.Errorf("Missing field %q with pointer mode %q", field, pointers)
+				t.Logf(out.String())
+			}
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	generated := regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+	schema := `<schema><element name="note" type="string" /></schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "notes"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if !generated.MatchString(lines[0]) || lines[1] != "" {
+		t.Errorf("Unexpected header %q", lines[:2])
+	}
+
+	out.Reset()
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "notes", Timestamp: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(out.String(), "\n")
+	if !generated.MatchString(lines[0]) || !strings.HasPrefix(lines[1], "// Generated at ") {
+		t.Errorf("Unexpected header %q with a timestamp", lines[:2])
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	schema := `<schema>
+	<element name="page">
+		<complexType>
+			<sequence>
+				<element name="title" type="string" />
+				<element name="margin" type="marginType" minOccurs="0" />
+			</sequence>
+			<attribute name="lang" type="string" default="en" />
+			<attribute name="columns" type="byte" default="+2" />
+			<attribute name="draft" type="boolean" default="1" />
+			<attribute name="scale" type="double" default="1.5" />
+			<attribute name="version" type="string" fixed="1.0" />
+			<attribute name="big" type="byte" default="300" />
+			<attribute name="created" type="dateTime" default="2020-01-01T00:00:00Z" />
+		</complexType>
+	</element>
+	<complexType name="marginType">
+		<attribute name="size" type="int" />
+	</complexType>
+</schema>`
+
+	for _, pointers := range []string{PointersOptional, PointersAll} {
+		var src bytes.Buffer
+		if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", Constructors: true, Pointers: pointers}); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(src.String(), "newMarginType") {
+			t.Errorf("Unexpected constructor of a type without defaults")
+		}
+		if doc := "// newPage returns a new page with the default values of its attributes."; !strings.Contains(src.String(), doc) {
+			t.Errorf("Missing the doc comment %q of the constructor", doc)
+		}
+
+		main := `package main
+
+import "fmt"
+
+func main() {
+	p := newPage()
+	fmt.Println(p.Lang, p.Columns, p.Draft, p.Scale, p.Version, p.Big)
+}
+`
+		if pointers == PointersAll {
+			main = strings.Replace(main, "p.Lang, p.Columns, p.Draft, p.Scale, p.Version", "*p.Lang, *p.Columns, *p.Draft, *p.Scale, *p.Version", 1)
+			main = strings.Replace(main, "p.Big)", "p.Big == nil)", 1)
+		}
+		out := runGenerated(t, src.String(), main)
+		want := "en 2 true 1.5 1.0 0\n"
+		if pointers == PointersAll {
+			want = "en 2 true 1.5 1.0 true\n"
+		}
+		if out != want {
+			t.Errorf("Constructor with pointer mode %s gave %q, want %q", pointers, out, want)
+		}
+	}
+}
+
+func TestEmptyComplexType(t *testing.T) {
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="urgent" minOccurs="0"><complexType/></element>
+				<element name="gift" type="markerType" minOccurs="0" />
+				<element name="seen" type="markerType" />
+			</sequence>
+		</complexType>
+	</element>
+	<element name="ping"><complexType/></element>
+	<complexType name="markerType"/>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	out = removeComments(out)
+	want := `
+type order struct {
+	XMLName xml.Name ` + "`xml:\"order\"`" + `
+	Urgent *urgent ` + "`xml:\"urgent,omitempty\"`" + `
+	Gift *markerType ` + "`xml:\"gift,omitempty\"`" + `
+	Seen markerType ` + "`xml:\"seen\"`" + `
+}
+
+type urgent struct {
+}
+
+type markerType struct {
+}
+
+type ping struct {
+	XMLName xml.Name ` + "`xml:\"ping\"`" + `
+}
+`
+	if strings.Join(strings.Fields(out.String()), "") != strings.Join(strings.Fields(want), "") {
+		t.Errorf("Unexpected generated Go source for empty complex types")
+		t.Logf(out.String())
+	}
+}
+
+func TestTypeMap(t *testing.T) {
+	schema := `<schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<element name="invoice">
+		<complexType>
+			<sequence>
+				<element name="total" type="xs:decimal" />
+				<element name="due" type="date" />
+				<element name="lines" type="int" />
+			</sequence>
+			<attribute name="issued" type="xs:date" />
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	opts := Options{Package: "invoices", Exported: true, TypeMap: map[string]string{
+		"xs:decimal": "github.com/shopspring/decimal.Decimal",
+		"date":       "example.com/civil/v2.Date",
+		"int":        "int64",
+	}}
+	if err := GenerateFrom(&out, strings.NewReader(schema), opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\t\"encoding/xml\"\n\t\"example.com/civil/v2\"\n\t\"github.com/shopspring/decimal\"\n",
+		"Total decimal.Decimal `xml:\"total\"`",
+		"Due civil.Date `xml:\"due\"`",
+		"Lines int64 `xml:\"lines\"`",
+		"Issued civil.Date `xml:\"issued,attr,omitempty\"`",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(out.String()), " "), strings.Join(strings.Fields(s), " ")) {
+			t.Errorf("Missing %q in the generated code", s)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	for _, m := range []map[string]string{
+		{"dateType": "time.Time"},
+		{"date": "Date"},
+		{"date": "example.com/civil.date"},
+		{"date": "example.com/a/civil.Date", "time": "example.com/b/civil.Time"},
+	} {
+		if err := GenerateFrom(&out, strings.NewReader(schema), Options{TypeMap: m}); err == nil {
+			t.Errorf("Expected an error for the type map %v", m)
+		}
+	}
+}
+
+func TestTypeMapChardata(t *testing.T) {
+	schema := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="cents">
+		<xs:restriction base="xs:decimal">
+			<xs:fractionDigits value="2" />
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:element name="price">
+		<xs:complexType>
+			<xs:simpleContent>
+				<xs:extension base="xs:decimal">
+					<xs:attribute name="currency" type="xs:string" />
+				</xs:extension>
+			</xs:simpleContent>
+		</xs:complexType>
+	</xs:element>
+	<xs:element name="discount">
+		<xs:complexType>
+			<xs:simpleContent>
+				<xs:extension base="cents">
+					<xs:attribute name="code" type="xs:string" />
+				</xs:extension>
+			</xs:simpleContent>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`
+
+	var out bytes.Buffer
+	opts := Options{Package: "shop", Exported: true, TypeMap: map[string]string{"xs:decimal": "example.com/money.Amount"}}
+	if err := GenerateFrom(&out, strings.NewReader(schema), opts); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"\"example.com/money\"",
+		"type Price struct { XMLName xml.Name `xml:\"price\"` Currency string `xml:\"currency,attr,omitempty\"` Price money.Amount `xml:\",chardata\"` }",
+		"type Discount struct { XMLName xml.Name `xml:\"discount\"` Code string `xml:\"code,attr,omitempty\"` Discount money.Amount `xml:\",chardata\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestCardinalityComments(t *testing.T) {
+	schema := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="note" type="xs:string" />
+	<xs:element name="order">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="id" type="xs:string" />
+				<xs:element name="line" type="xs:string" minOccurs="1" maxOccurs="unbounded" />
+				<xs:element name="tag" type="xs:string" minOccurs="0" maxOccurs="5" />
+				<xs:element ref="note" minOccurs="0" />
+			</xs:sequence>
+			<xs:attribute name="ref" type="xs:string" />
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{CardinalityComments: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"Ref string `xml:\"ref,attr,omitempty\"` ID string `xml:\"id\"` // minOccurs=1 maxOccurs=1 ",
+		"Line []string `xml:\"line\"` // minOccurs=1 maxOccurs=unbounded ",
+		"Tag []string `xml:\"tag,omitempty\"` // minOccurs=0 maxOccurs=5 ",
+		"Note *string `xml:\"note,omitempty\"` // minOccurs=0 maxOccurs=1 ",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	out.Reset()
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "minOccurs") {
+		t.Errorf("Unexpected cardinality comments in\n%s", out.String())
+	}
+}
+
+func TestSequenceOrder(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<complexType name="base">
+		<sequence>
+			<element name="a" type="string" />
+			<group ref="bc" />
+		</sequence>
+	</complexType>
+	<group name="bc">
+		<sequence>
+			<element name="b" type="string" />
+			<element name="c" type="string" />
+		</sequence>
+	</group>
+	<group name="f">
+		<sequence>
+			<element name="f" type="string" />
+		</sequence>
+	</group>
+	<element name="derived">
+		<complexType>
+			<complexContent>
+				<extension base="base">
+					<sequence>
+						<element name="d" type="string" />
+						<choice>
+							<element name="e1" type="string" />
+							<element name="e2" type="string" />
+						</choice>
+						<group ref="f" />
+						<element name="g" type="string" />
+					</sequence>
+				</extension>
+			</complexContent>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range roots[0].Children {
+		got = append(got, c.Name)
+	}
+	if exp := []string{"a", "b", "c", "d", "e1", "e2", "f", "g"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("Unexpected field order %q, want %q", got, exp)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"order.xsd": `<schema>
+	<include schemaLocation="types.xsd" />
+	<element name="order" type="orderType" />
+</schema>`,
+		// Refers to a type of another file that it does not include
+		"invoice.xsd": `<schema>
+	<element name="invoice" type="orderType" />
+</schema>`,
+		"types.xsd": `<schema>
+	<complexType name="orderType">
+		<sequence>
+			<element name="id" type="string" />
+		</sequence>
+	</complexType>
+</schema>`,
+		"notes.txt":    `not a schema`,
+		"sub/note.xsd": `<schema><element name="note" type="string" /></schema>`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, recursive := range []bool{false, true} {
+		schemas, err := parseXSDDir(dir, recursive, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots, err := newBuilder(schemas).buildXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range roots {
+			got = append(got, e.Name+" "+e.Type)
+		}
+		exp := []string{"invoice orderType", "order orderType"}
+		if recursive {
+			exp = append(exp, "note string")
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("Unexpected roots %q, want %q", got, exp)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Generate(&out, dir, Options{Package: "test", Exported: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "type OrderType struct"); n != 1 {
+		t.Errorf("Got %d OrderType structs, want 1", n)
+		t.Log(out.String())
+	}
+
+	if _, err := parseXSDDir(filepath.Join(dir, "sub", "empty"), false, true); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	schema := `<schema>
+	<complexType name="partyType">
+		<sequence>
+			<element name="name" type="string" />
+			<element name="since" type="date" />
+		</sequence>
+	</complexType>
+	<simpleType name="skuType">
+		<restriction base="string">
+			<pattern value="[A-Z]{3}-[0-9]+" />
+		</restriction>
+	</simpleType>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="buyer" type="partyType" />
+				<element name="line" maxOccurs="unbounded">
+					<complexType>
+						<attribute name="sku" type="skuType" />
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<element name="invoice">
+		<complexType>
+			<sequence>
+				<element name="seller" type="partyType" />
+				<element name="total" type="decimal" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	files, err := GenerateFilesFrom(strings.NewReader(schema), Options{Package: "main", Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if exp := []string{"invoice.go", "order.go", "partytype.go"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("Unexpected files %q, want %q", names, exp)
+	}
+	if order := string(files["order.go"]); !strings.Contains(order, "type line struct") || strings.Contains(order, "type partyType struct") {
+		t.Errorf("Missing the inline types of the root, or having the named type, in its file:\n%s", order)
+	}
+	if party := string(files["partytype.go"]); !strings.Contains(party, "type partyType struct") || !strings.Contains(party, `"time"`) {
+		t.Errorf("Missing the named type, or its imports, in its file:\n%s", party)
+	}
+	for _, name := range []string{"order.go", "invoice.go"} {
+		if src := string(files[name]); strings.Contains(src, `"time"`) {
+			t.Errorf("Unexpected imports of the types of another file in %s:\n%s", name, src)
+		}
+	}
+	if invoice := string(files["invoice.go"]); strings.Contains(invoice, `"regexp"`) {
+		t.Errorf("Unexpected imports of the types of another file:\n%s", invoice)
+	}
+
+	files["main.go"] = []byte(`package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(line{Sku: "ABC-1"}.Validate(), invoice{Total: 1.5}.Total, order{}.Buyer.Since.IsZero())
+}
+`)
+	out := runFiles(t, files)
+	if want := "<nil> 1.5 true\n"; out != want {
+		t.Errorf("Split files gave %q, want %q", out, want)
+	}
+
+	if _, err := GenerateFilesFrom(strings.NewReader(schema), Options{}); err == nil {
+		t.Errorf("Expected an error without a package name")
+	}
+}
+
+func TestGoRegexp(t *testing.T) {
+	for _, tst := range []struct {
+		pattern, exp string
+	}{
+		{`[0-9]{4} ?[A-Z]{2}`, `[0-9]{4} ?[A-Z]{2}`},
+		{`\d+\.\d*`, `[\p{Nd}]+\.[\p{Nd}]*`},
+		{`\i\c*`, `[\p{L}_:][\p{L}\p{M}\p{Nd}._:\-]*`},
+		{`[\i\-]\C`, `[\p{L}_:\-][^\p{L}\p{M}\p{Nd}._:\-]`},
+		{`\w\W`, `[^\p{P}\p{Z}\p{C}][\p{P}\p{Z}\p{C}]`},
+		{`\p{Lu}\p{IsBasicLatin}[\p{IsGreek}x]\P{IsGreek}`, `\p{Lu}[\x00-\x7F][\x{370}-\x{3FF}x][^\x{370}-\x{3FF}]`},
+		{`^a$.[^.]`, `\^a\$[^\n\r][^.]`},
+		{`[\w.-]+`, `(?:[.-]|[^\p{P}\p{Z}\p{C}])+`},
+		{`[\I][\D\S]`, `[^\p{L}_:](?:[\S]|[^\p{Nd}])`},
+		{`[x\P{IsGreek}]`, `(?:[x]|[^\x{370}-\x{3FF}])`},
+		{`\p{IsHalfwidthandFullwidthForms}+`, `[\x{FF00}-\x{FFEF}]+`},
+	} {
+		got, err := goRegexp(tst.pattern)
+		if err != nil {
+			t.Errorf("Translating %q: %s", tst.pattern, err)
+		} else if got != tst.exp {
+			t.Errorf("Translated %q to %q, want %q", tst.pattern, got, tst.exp)
+		}
+	}
+
+	expr, err := goRegexp(`[\w.-]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile("^(?:" + expr + ")$")
+	for s, want := range map[string]bool{"é.b-c1": true, "a b": false, "a_b": false} {
+		if got := re.MatchString(s); got != want {
+			t.Errorf("[\\w.-]+ matching %q = %v, want %v", s, got, want)
+		}
+	}
+
+	for _, pattern := range []string{`[a-z-[aeiou]]`, `[^\w.]`, `\p{IsKlingon}`, `\p{L`, `(`} {
+		if _, err := goRegexp(pattern); err == nil {
+			t.Errorf("Expected an error translating %q", pattern)
+		}
+	}
+}
+
+func TestPatternTypes(t *testing.T) {
+	schema := `<schema>
+	<simpleType name="postalCodeType">
+		<annotation><documentation>A Dutch postal code</documentation></annotation>
+		<restriction base="string">
+			<pattern value="\d{4} ?[A-Z]{2}" />
+		</restriction>
+	</simpleType>
+	<element name="address">
+		<complexType>
+			<sequence>
+				<element name="postalCode" type="postalCodeType" />
+				<element name="former" type="postalCodeType" minOccurs="0" maxOccurs="unbounded" />
+				<element name="street" type="string" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", PatternTypes: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"type postalCode string",
+		"// A Dutch postal code",
+		"PostalCode postalCode `xml:\"postalCode\"`",
+		// Named after their element, unlike named enumerations
+		"type former string",
+		"Former []former `xml:\"former,omitempty\"`",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(src.String()), " "), s) {
+			t.Errorf("Missing %q in the generated code", s)
+		}
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	var a address
+	if err := xml.Unmarshal([]byte("<address><postalCode>1234 AB</postalCode><former>12345</former></address>"), &a); err != nil {
+		panic(err)
+	}
+	fmt.Println(a.PostalCode.Validate())
+	fmt.Println(a.Former[0].Validate())
+}
+`)
+	want := "<nil>\nformer: \"12345\" does not match the pattern \\d{4} ?[A-Z]{2}\n"
+	if out != want {
+		t.Errorf("Pattern types gave %q, want %q", out, want)
+	}
+}
+
+func TestPatternAlternatives(t *testing.T) {
+	schema := `<schema>
+	<simpleType name="codeType">
+		<restriction base="string">
+			<pattern value="[A-Z]{3}" />
+			<pattern value="\d{3}" />
+		</restriction>
+	</simpleType>
+	<element name="item">
+		<complexType>
+			<sequence>
+				<element name="sku" type="codeType" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	for _, tst := range []struct {
+		opts           Options
+		validate, name string
+	}{
+		{Options{Package: "main", Validate: true}, "item{Sku: code}.Validate()", "sku"},
+		// Named after their element, like other pattern types
+		{Options{Package: "main", PatternTypes: true}, "sku(code).Validate()", "sku"},
+	} {
+		var src bytes.Buffer
+		if err := GenerateFrom(&src, strings.NewReader(schema), tst.opts); err != nil {
+			t.Fatal(err)
+		}
+		if s := `"^(?:(?:[A-Z]{3})|(?:[\\p{Nd}]{3}))$"`; !strings.Contains(src.String(), s) {
+			t.Errorf("Missing %s in the generated code\n%s", s, src.String())
+		}
+
+		out := runGenerated(t, src.String(), `package main
+
+import "fmt"
+
+func main() {
+	for _, code := range []string{"ABC", "123", "AB1"} {
+		fmt.Println(`+tst.validate+`)
+	}
+}
+`)
+		want := "<nil>\n<nil>\n" + tst.name + ": \"AB1\" does not match the pattern [A-Z]{3}|\\d{3}\n"
+		if out != want {
+			t.Errorf("Patterns with %+v gave %q, want %q", tst.opts, out, want)
+		}
+	}
+}
+
+func TestSimpleRoots(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<element name="code" type="string" maxOccurs="unbounded" />
+	<element name="codes">
+		<simpleType>
+			<list itemType="int" />
+		</simpleType>
+	</element>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element ref="code" maxOccurs="unbounded" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The roots are structs for their chardata, while the ref stays a
+	// slice of strings
+	for i, exp := range []xmlTree{
+		{Name: "code", Type: "string", Root: true, List: true, Cdata: true},
+		{Name: "codes", Type: "int32", Root: true, Cdata: true, SimpleList: true},
+	} {
+		e := roots[i]
+		got := xmlTree{Name: e.Name, Type: e.Type, Root: e.Root, List: e.List, Cdata: e.Cdata, SimpleList: e.SimpleList}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("Unexpected simple root %+v, want %+v", got, exp)
+		}
+	}
+	if c := roots[2].Children[0]; c.Cdata || !c.List || c.Type != "string" {
+		t.Errorf("Unexpected ref to a simple root %+v", c)
+	}
+
+	var out bytes.Buffer
+	if err := (generator{cdataName: "Value"}).do(&out, roots[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(strings.Fields(out.String()), " "); !strings.Contains(s, "type code struct { XMLName xml.Name `xml:\"code\"` Value string `xml:\",chardata\"` }") {
+		t.Errorf("Unexpected struct of a simple root:\n%s", out.String())
+	}
+}
+
+func TestFlattenWrappers(t *testing.T) {
+	schema := `<schema>
+	<complexType name="itemsType">
+		<sequence>
+			<element name="item" maxOccurs="unbounded">
+				<complexType>
+					<attribute name="sku" type="string" />
+				</complexType>
+			</element>
+		</sequence>
+	</complexType>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="items" type="itemsType" />
+				<element name="notes">
+					<complexType>
+						<sequence>
+							<element name="note" type="string" minOccurs="0" maxOccurs="unbounded" />
+						</sequence>
+					</complexType>
+				</element>
+				<element name="tags">
+					<complexType>
+						<sequence>
+							<element name="tag" type="string" maxOccurs="unbounded" />
+						</sequence>
+						<attribute name="scheme" type="string" />
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", FlattenWrappers: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(src.String()), " ")
+	for _, exp := range []string{
+		"Items []item `xml:\"items>item\"`",
+		"Notes []string `xml:\"notes>note,omitempty\"`",
+		"Tags tags `xml:\"tags\"`",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if strings.Contains(s, "type itemsType struct") {
+		t.Errorf("Unexpected struct of a flattened wrapper")
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	in := "<order><items><item sku=\"a\"></item><item sku=\"b\"></item></items><notes><note>x</note></notes><tags scheme=\"s\"><tag>t</tag></tags></order>"
+	var o order
+	if err := xml.Unmarshal([]byte(in), &o); err != nil {
+		panic(err)
+	}
+	fmt.Println(len(o.Items), o.Items[1].Sku, o.Notes, o.Tags.Tag)
+	out, err := xml.Marshal(o)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(out) == in)
+}
+`)
+	if want := "2 b [x] [t]\ntrue\n"; out != want {
+		t.Errorf("Flattened wrappers gave %q, want %q", out, want)
+	}
+}
+
+func TestInlineTypeNames(t *testing.T) {
+	// Inline types named like built-in Go types are still structs
+	schema := `<schema>
+	<element name="config">
+		<complexType>
+			<sequence>
+				<element name="string" maxOccurs="unbounded">
+					<complexType>
+						<attribute name="key" type="string" />
+					</complexType>
+				</element>
+				<element name="bool">
+					<complexType>
+						<sequence>
+							<element ref="config" minOccurs="0" />
+						</sequence>
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test", Exported: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"String []String `xml:\"string\"`",
+		"Bool Bool `xml:\"bool\"`",
+		"type String struct { Key string `xml:\"key,attr,omitempty\"` }",
+		"type Bool struct { Config *Config `xml:\"config,omitempty\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	// Unexported, they would shadow the built-in types
+	out.Reset()
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	s = strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"String []xstring `xml:\"string\"`",
+		"type xstring struct { Key string `xml:\"key,attr,omitempty\"` }",
+		"type xbool struct {",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+}
+
+func TestPackageNames(t *testing.T) {
+	// Types named like the packages of the generated code are renamed, so
+	// that they do not shadow them
+	schema := `<schema>
+	<element name="strings">
+		<complexType>
+			<sequence>
+				<element name="strconv">
+					<complexType>
+						<sequence>
+							<element name="values">
+								<simpleType>
+									<list itemType="int" />
+								</simpleType>
+							</element>
+						</sequence>
+					</complexType>
+				</element>
+				<element name="json">
+					<complexType>
+						<attribute name="id" type="string" />
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"type xstrings struct", "type xstrconv struct", "type xjson struct"} {
+		if !strings.Contains(src.String(), exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Fatal(src.String())
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	var s xstrings
+	if err := xml.Unmarshal([]byte("<strings><strconv><values>1 2</values></strconv><json id=\"a\"></json></strings>"), &s); err != nil {
+		panic(err)
+	}
+	fmt.Println(s.Strconv.Values, s.JSON.ID)
+}
+`)
+	if want := "[1 2] a\n"; out != want {
+		t.Errorf("Types named like packages gave %q, want %q", out, want)
+	}
+}
+
+func TestUnexported(t *testing.T) {
+	schema := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="Order" type="PurchaseOrderType"/>
+  <xs:complexType name="PurchaseOrderType">
+    <xs:sequence>
+      <xs:element name="Link" type="URLType" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="Status" type="StatusKind"/>
+  </xs:complexType>
+  <xs:complexType name="URLType">
+    <xs:attribute name="Href" type="xs:anyURI"/>
+  </xs:complexType>
+  <xs:simpleType name="StatusKind">
+    <xs:restriction base="xs:string">
+      <xs:enumeration value="open"/>
+    </xs:restriction>
+  </xs:simpleType>
+</xs:schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test", Unexported: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type purchaseOrderType struct { XMLName xml.Name `xml:\"Order\"`",
+		"Status string `xml:\"Status,attr,omitempty\"`",
+		"Link []urlType `xml:\"Link\"`",
+		"type urlType struct { Href string `xml:\"Href,attr,omitempty\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test", Exported: true, Unexported: true})
+	if err == nil {
+		t.Error("Expected an error for exported and unexported types")
+	}
+}
+
+func TestSimpleContentList(t *testing.T) {
+	schema := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="integerList">
+    <xs:list itemType="xs:integer"/>
+  </xs:simpleType>
+  <xs:complexType name="readings">
+    <xs:simpleContent>
+      <xs:extension base="integerList">
+        <xs:attribute name="unit" type="xs:string"/>
+      </xs:extension>
+    </xs:simpleContent>
+  </xs:complexType>
+  <xs:element name="sensor">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="values" type="readings"/>
+        <xs:element name="previous" type="readings" minOccurs="0"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test", ChardataName: "Value"}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"Values readings `xml:\"values\"`",
+		"Previous *readings `xml:\"previous,omitempty\"`",
+		"type readings struct { Unit string `xml:\"unit,attr,omitempty\"` Value intList `xml:\",chardata\"` }",
+		"type intList []int",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+}
+
+func TestListDecoding(t *testing.T) {
+	schema := `<schema>
+	<simpleType name="integers">
+		<list itemType="integer" />
+	</simpleType>
+	<element name="sample">
+		<complexType>
+			<sequence>
+				<element name="values" type="integers" />
+				<element name="flags" minOccurs="0">
+					<simpleType>
+						<list itemType="boolean" />
+					</simpleType>
+				</element>
+				<element name="weights" minOccurs="0">
+					<complexType>
+						<simpleContent>
+							<extension base="integers">
+								<attribute name="unit" type="string" />
+							</extension>
+						</simpleContent>
+					</complexType>
+				</element>
+				<element name="days" minOccurs="0">
+					<simpleType>
+						<list itemType="date" />
+					</simpleType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", JSON: true, ChardataName: "Value"}); err != nil {
+		t.Fatal(err)
+	}
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+func main() {
+	var s sample
+	doc := "<sample><values> 1 2\n\t3 </values><flags>true false</flags>" +
+		"<weights unit=\"kg\">4 5</weights><days>2024-01-02 2024-01-03</days></sample>"
+	if err := xml.Unmarshal([]byte(doc), &s); err != nil {
+		panic(err)
+	}
+	fmt.Println(reflect.DeepEqual([]int(s.Values), []int{1, 2, 3}), s.Flags, s.Weights.Value, s.Weights.Unit, len(s.Days))
+
+	out, err := xml.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout.Write(out)
+	fmt.Println()
+
+	js, err := json.Marshal(s.Values)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(js))
+
+	if err := xml.Unmarshal([]byte("<sample><values>1 x</values></sample>"), &s); err == nil {
+		panic("expected an error for an invalid value")
+	}
+}
+`)
+	want := "true [true false] [4 5] kg 2\n" +
+		`<sample><values>1 2 3</values><flags>true false</flags><weights unit="kg">4 5</weights><days>2024-01-02 2024-01-03</days></sample>` + "\n" +
+		"[1,2,3]\n"
+	if out != want {
+		t.Errorf("Lists gave\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestChoiceInterface(t *testing.T) {
+	schema := `<schema>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="shape" type="shape" maxOccurs="unbounded" />
+			</sequence>
+		</complexType>
+	</element>
+	<complexType name="shape">
+		<choice>
+			<element name="circle">
+				<complexType>
+					<attribute name="r" type="int" />
+				</complexType>
+			</element>
+			<element name="label" type="string" />
+			<element name="point" type="int" maxOccurs="unbounded" />
+			<element name="path" type="ints" />
+		</choice>
+		<attribute name="id" type="string" />
+	</complexType>
+	<simpleType name="ints">
+		<list itemType="int" />
+	</simpleType>
+</schema>`
+
+	if err := GenerateFrom(ioutil.Discard, strings.NewReader(schema), Options{Choice: "union"}); err == nil {
+		t.Error("Expected an error for an unknown choice mode")
+	}
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", Choice: ChoiceInterface}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"type shape struct { ID string `xml:\"id,attr,omitempty\"`",
+		"Choice shapeChoice `xml:\"-\"` }",
+		"type shapeChoice interface { isShapeChoice() }",
+		"type shapeCircle struct{ circle }",
+		"type shapeLabel string",
+		"type shapePoint []int32",
+		"type shapePath struct{ int32List }",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(src.String()), " "), s) {
+			t.Errorf("Missing %q in the generated code", s)
+		}
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var d drawing
+	doc := "<drawing><shape id=\"a\"><circle r=\"2\"></circle></shape><shape id=\"b\"><label>x</label></shape><shape><point>1</point><point>2</point></shape><shape><path>1 2 3</path></shape></drawing>"
+	if err := xml.Unmarshal([]byte(doc), &d); err != nil {
+		panic(err)
+	}
+	for _, s := range d.Shape {
+		switch c := s.Choice.(type) {
+		case *shapeCircle:
+			fmt.Println(s.ID, "circle", c.R)
+		case *shapeLabel:
+			fmt.Println(s.ID, "label", *c)
+		case *shapePoint:
+			fmt.Println(s.ID, "point", *c)
+		case *shapePath:
+			fmt.Println(s.ID, "path", c.int32List)
+		}
+	}
+
+	d.Shape[1].Choice = &shapeCircle{circle{R: 3}}
+	out, err := xml.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout.Write(out)
+}
+`)
+	want := "a circle 2\nb label x\n point [1 2]\n path [1 2 3]\n" +
+		`<drawing><shape id="a"><circle r="2"></circle></shape><shape id="b"><circle r="3"></circle></shape><shape><point>1</point><point>2</point></shape><shape><path>1 2 3</path></shape></drawing>`
+	if out != want {
+		t.Errorf("Choice interfaces gave\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestRepeatedChoice(t *testing.T) {
+	schema := `<schema>
+	<element name="drawing">
+		<complexType>
+			<sequence>
+				<element name="title" type="string" />
+			</sequence>
+			<choice maxOccurs="unbounded">
+				<element name="circle">
+					<complexType>
+						<attribute name="r" type="int" />
+					</complexType>
+				</element>
+				<element name="label" type="string" />
+			</choice>
+		</complexType>
+	</element>
+</schema>`
+
+	var flat bytes.Buffer
+	if err := GenerateFrom(&flat, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	exp := "type drawing struct { XMLName xml.Name `xml:\"drawing\"` Title string `xml:\"title\"` " +
+		"Circle []circle `xml:\"circle,omitempty\"` Label []string `xml:\"label,omitempty\"` }"
+	if !strings.Contains(strings.Join(strings.Fields(flat.String()), " "), exp) {
+		t.Errorf("Missing %q in the generated code\n%s", exp, flat.String())
+	}
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", Choice: ChoiceInterface}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"Choice []drawingChoice `xml:\"-\"` }",
+		"type drawingChoiceElement struct { choice drawingChoice }",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(src.String()), " "), s) {
+			t.Errorf("Missing %q in the generated code", s)
+		}
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var d drawing
+	doc := "<drawing><title>t</title><label>a</label><circle r=\"2\"></circle><unknown/><label>b</label></drawing>"
+	if err := xml.Unmarshal([]byte(doc), &d); err != nil {
+		panic(err)
+	}
+	fmt.Println(d.Title)
+	for _, c := range d.Choice {
+		switch c := c.(type) {
+		case *drawingCircle:
+			fmt.Println("circle", c.R)
+		case *drawingLabel:
+			fmt.Println("label", *c)
+		}
+	}
+
+	d.Choice = append(d.Choice, &drawingCircle{circle{R: 3}})
+	out, err := xml.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout.Write(out)
+}
+`)
+	want := "t\nlabel a\ncircle 2\nlabel b\n" +
+		`<drawing><title>t</title><label>a</label><circle r="2"></circle><label>b</label><circle r="3"></circle></drawing>`
+	if out != want {
+		t.Errorf("Repeated choice gave\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="customer" minOccurs="0">
+					<complexType>
+						<sequence>
+							<element name="address" minOccurs="0">
+								<complexType>
+									<sequence>
+										<element name="city" type="string" minOccurs="0" />
+									</sequence>
+								</complexType>
+							</element>
+						</sequence>
+						<attribute name="vip" type="boolean" />
+					</complexType>
+				</element>
+				<element name="note" type="string" minOccurs="0" maxOccurs="unbounded" />
+				<element name="getCustomer" type="string" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "main", Accessors: true, Pointers: PointersAll}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"func (v *customer) GetAddress() *address { if v == nil { return nil } return v.Address }",
+		"func (v *address) GetCity() string { if v == nil || v.City == nil { var zero string return zero } return *v.City }",
+		"func (v *customer) GetVip() bool {",
+		"func (v *order) GetGetCustomer() string {",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	for _, unexp := range []string{"GetNote", "func (v *order) GetCustomer()"} {
+		if strings.Contains(s, unexp) {
+			t.Errorf("Unexpected %q in the generated code", unexp)
+		}
+	}
+	if t.Failed() {
+		t.Fatal(out.String())
+	}
+
+	res := runGenerated(t, out.String(), `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+func main() {
+	var nilOrder *order
+	fmt.Printf("%q %v\n", nilOrder.GetGetCustomer(), nilOrder.GetGetCustomer() == "")
+	for _, doc := range []string{
+		"<order></order>",
+		"<order><customer></customer></order>",
+		"<order><customer vip=\"true\"><address><city>Oslo</city></address></customer></order>",
+	} {
+		o := new(order)
+		if err := xml.Unmarshal([]byte(doc), o); err != nil {
+			panic(err)
+		}
+		c := o.Customer
+		fmt.Printf("%q %v\n", c.GetAddress().GetCity(), c.GetVip())
+	}
+}
+`)
+	want := `"" true
+"" false
+"" false
+"Oslo" true
+`
+	if res != want {
+		t.Errorf("Accessors gave\n%s\nwant\n%s", res, want)
+	}
+}
+
+func TestWSDL(t *testing.T) {
+	wsdl := `<?xml version="1.0"?>
+<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/"
+		xmlns:xsd="http://www.w3.org/2001/XMLSchema"
+		xmlns:tns="http://example.com/orders"
+		xmlns:com="http://example.com/common"
+		targetNamespace="http://example.com/orders">
+	<wsdl:types>
+		<xsd:schema targetNamespace="http://example.com/common">
+			<xsd:complexType name="money">
+				<xsd:sequence>
+					<xsd:element name="amount" type="xsd:decimal" />
+					<xsd:element name="currency" type="xsd:string" />
+				</xsd:sequence>
+			</xsd:complexType>
+		</xsd:schema>
+		<xsd:schema targetNamespace="http://example.com/orders">
+			<xsd:import namespace="http://example.com/common" />
+			<xsd:element name="getOrder">
+				<xsd:complexType>
+					<xsd:sequence>
+						<xsd:element name="id" type="xsd:string" />
+						<xsd:element name="total" type="com:money" />
+						<xsd:any />
+					</xsd:sequence>
+				</xsd:complexType>
+			</xsd:element>
+		</xsd:schema>
+	</wsdl:types>
+	<wsdl:message name="getOrderRequest">
+		<wsdl:part name="parameters" element="tns:getOrder" />
+	</wsdl:message>
+</wsdl:definitions>`
+
+	var out, warnings bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(wsdl), Options{Package: "orders", Warnings: &warnings}); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("Unexpected warnings\n%s", warnings.String())
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type getOrder struct { XMLName xml.Name `xml:\"http://example.com/orders getOrder\"` ID string `xml:\"id\"` Total money `xml:\"total\"` }",
+		"type money struct { Amount float64 `xml:\"amount\"` Currency string `xml:\"currency\"` }",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	// The WSDL elements are not reported, but those of its schemas are
+	reports, err := CheckFrom(strings.NewReader(wsdl), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"<stdin>:23: unsupported any in element 'getOrder'"}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("Got reports %q, want %q", reports, want)
+	}
+
+	if _, err := CheckFrom(strings.NewReader(`<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" />`), Options{}); err == nil {
+		t.Error("Expected an error for a WSDL without schemas")
+	}
+}
+
+func TestEnumTypes(t *testing.T) {
+	// A named enumeration is one type, named after the simpleType, while
+	// inline ones are types of their own, even for elements of the same name
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="state" type="stateType" />
+				<element name="previous" type="stateType" />
+				<element name="code">
+					<simpleType>
+						<restriction base="int">
+							<enumeration value="-1" />
+							<enumeration value="1" />
+							<enumeration value="08" />
+						</restriction>
+					</simpleType>
+				</element>
+				<element name="status">
+					<simpleType>
+						<restriction base="string">
+							<enumeration value="new" />
+						</restriction>
+					</simpleType>
+				</element>
+				<element name="item">
+					<complexType>
+						<sequence>
+							<element name="status">
+								<simpleType>
+									<restriction base="string">
+										<enumeration value="sold" />
+									</restriction>
+								</simpleType>
+							</element>
+						</sequence>
+					</complexType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+	<simpleType name="stateType">
+		<restriction base="string">
+			<enumeration value="open" />
+			<enumeration value="Open" />
+			<enumeration value="closed" />
+		</restriction>
+	</simpleType>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(src.String()), " ")
+	for _, exp := range []string{
+		"State stateType `xml:\"state\"`",
+		"Previous stateType `xml:\"previous\"`",
+		`stateTypeOpen stateType = "open" stateTypeOpen2 stateType = "Open"`,
+		"code1 code = -1 code12 code = 1 code08 code = 8",
+		"Status status `xml:\"status\"`",
+		"Status status2 `xml:\"status\"`",
+		`status2Sold status2 = "sold"`,
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if n := strings.Count(s, "type stateType "); n != 1 {
+		t.Errorf("stateType generated %d times, want once", n)
+	}
+	if t.Failed() {
+		t.Fatal(src.String())
+	}
+
+	out := runGenerated(t, src.String(), `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(stateTypeOpen, stateTypeOpen2, code1, code12, code08, statusNew, status2Sold)
+}
+`)
+	if want := "open Open -1 1 8 new sold\n"; out != want {
+		t.Errorf("Enumeration constants gave %q, want %q", out, want)
+	}
+}
+
+func TestEnumMethods(t *testing.T) {
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="status">
+					<simpleType>
+						<restriction base="string">
+							<enumeration value="open" />
+							<enumeration value="closed" />
+						</restriction>
+					</simpleType>
+				</element>
+				<element name="priority">
+					<simpleType>
+						<restriction base="int">
+							<enumeration value="1" />
+							<enumeration value="2" />
+						</restriction>
+					</simpleType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test", EnumMethods: true}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		`"strconv"`,
+		"func (v status) String() string { return string(v) }",
+		"func (v status) IsValid() bool { switch v { case statusOpen, statusClosed: return true } return false }",
+		"func (v priority) String() string { return strconv.FormatInt(int64(v), 10) }",
+		"case priority1, priority2:",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	out.Reset()
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{Package: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "IsValid") || strings.Contains(out.String(), "strconv") {
+		t.Errorf("Enumeration methods generated without EnumMethods:\n%s", out.String())
+	}
+}
+
+func TestEnumIsValid(t *testing.T) {
+	// Values only differing in case or sign are distinct cases of IsValid
+	schema := `<schema>
+	<element name="order">
+		<complexType>
+			<sequence>
+				<element name="status">
+					<simpleType>
+						<restriction base="string">
+							<enumeration value="open" />
+							<enumeration value="Open" />
+						</restriction>
+					</simpleType>
+				</element>
+				<element name="balance">
+					<simpleType>
+						<restriction base="int">
+							<enumeration value="-1" />
+							<enumeration value="1" />
+						</restriction>
+					</simpleType>
+				</element>
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main", EnumMethods: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := runGenerated(t, src.String(), `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(status("open").IsValid(), status("Open").IsValid(), status("OPEN").IsValid())
+	fmt.Println(balance(-1).IsValid(), balance(1).IsValid(), balance(0).IsValid(), balance(-1))
+}
+`)
+	if want := "true true false\ntrue true false -1\n"; out != want {
+		t.Errorf("IsValid gave %q, want %q", out, want)
+	}
+}
+
+func TestDuplicateAttributes(t *testing.T) {
+	var schema xsdSchema
+	if err := xml.Unmarshal([]byte(`<schema>
+	<attributeGroup name="audit">
+		<attribute name="created" type="dateTime" />
+		<attribute name="id" type="int" use="required" />
+	</attributeGroup>
+	<complexType name="base">
+		<attribute name="version" type="string" />
+	</complexType>
+	<element name="order">
+		<complexType>
+			<complexContent>
+				<extension base="base">
+					<attribute name="id" type="string" />
+					<attribute name="version" type="int" />
+					<attributeGroup ref="audit" />
+					<attributeGroup ref="audit" />
+				</extension>
+			</complexContent>
+		</complexType>
+	</element>
+</schema>`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := newBuilder([]xsdSchema{schema}).buildXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xmlAttrib{
+		{Name: "version", Type: "int32", Optional: true},
+		{Name: "id", Type: "int32"},
+		{Name: "created", Type: "time.Time", Optional: true},
+	}
+	if !reflect.DeepEqual(roots[0].Attribs, want) {
+		t.Errorf("Unexpected attributes")
+		pretty.Println(roots[0].Attribs)
+	}
+}
+
+func TestForwardRefs(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("needs the go tool")
+	}
+
+	// The types and the element note are used before they are defined,
+	// note within an expansion of itself
+	schema := `<schema>
+	<element name="catalog">
+		<complexType>
+			<sequence>
+				<element name="item" type="itemType" maxOccurs="unbounded" />
+				<element name="node" type="nodeType" />
+			</sequence>
+		</complexType>
+	</element>
+	<complexType name="itemType">
+		<sequence>
+			<element name="price" type="priceType" />
+			<element name="related" type="itemType" minOccurs="0" />
+			<element ref="note" minOccurs="0" />
+		</sequence>
+	</complexType>
+	<complexType name="nodeType">
+		<sequence>
+			<element name="child" type="nodeType" minOccurs="0" maxOccurs="unbounded" />
+			<element name="item" type="itemType" minOccurs="0" />
+		</sequence>
+	</complexType>
+	<complexType name="priceType">
+		<simpleContent>
+			<extension base="decimal">
+				<attribute name="currency" type="string" />
+			</extension>
+		</simpleContent>
+	</complexType>
+	<element name="note">
+		<complexType>
+			<sequence>
+				<element name="item" type="itemType" minOccurs="0" />
+			</sequence>
+		</complexType>
+	</element>
+</schema>`
+
+	var src bytes.Buffer
+	if err := GenerateFrom(&src, strings.NewReader(schema), Options{Package: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"catalog", "itemType", "nodeType", "priceType", "note"} {
+		if n := strings.Count(src.String(), "type "+name+" struct"); n != 1 {
+			t.Errorf("Type %s is generated %d times", name, n)
+		}
+	}
+	if strings.Contains(src.String(), "note2") {
+		t.Error("The top-level element note has more than one type")
+	}
+
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"go.mod":       "module forward\n",
+		"generated.go": src.String(),
+		"main.go":      "package main\n\nfunc main() {}\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "vet", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s\n%s\n%s", err, out, src.String())
+	}
+}
+
+func TestNestedImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goxsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Every schemaLocation is relative to the schema declaring it, not to
+	// the master schema or the working directory
+	for name, content := range map[string]string{
+		"master.xsd": `<schema xmlns:c="urn:common">
+	<import namespace="urn:common" schemaLocation="common/types.xsd" />
+	<element name="order" type="c:orderType" />
+</schema>`,
+		"common/types.xsd": `<schema targetNamespace="urn:common" xmlns:c="urn:common">
+	<include schemaLocation="units.xsd" />
+	<import schemaLocation="../shared/base.xsd" />
+	<complexType name="orderType">
+		<sequence>
+			<element name="weight" type="c:weightType" />
+			<element name="id" type="idType" />
+		</sequence>
+	</complexType>
+</schema>`,
+		"common/units.xsd": `<schema targetNamespace="urn:common">
+	<simpleType name="weightType">
+		<restriction base="decimal" />
+	</simpleType>
+</schema>`,
+		"shared/base.xsd": `<schema>
+	<simpleType name="idType">
+		<restriction base="int" />
+	</simpleType>
+</schema>`,
+		"elsewhere/README": "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(dir, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	var out, warnings bytes.Buffer
+	if err := Generate(&out, filepath.Join("..", "master.xsd"), Options{Warnings: &warnings}); err != nil {
+		t.Fatal(err)
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("Unexpected warnings\n%s", warnings.String())
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	if exp := "type orderType struct { XMLName xml.Name `xml:\"order\"` Weight float64 `xml:\"weight\"` ID int32 `xml:\"id\"` }"; !strings.Contains(s, exp) {
+		t.Errorf("Missing %q in the generated code\n%s", exp, out.String())
+	}
+}
+
+func TestIgnoredConstructs(t *testing.T) {
+	schema := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:notation name="png" public="image/png" />
+	<xs:element name="library">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="book" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="title" type="xs:string" />
+						</xs:sequence>
+						<xs:attribute name="isbn" type="xs:string" use="required" />
+						<xs:attribute name="sequel" type="xs:string" />
+					</xs:complexType>
+				</xs:element>
+			</xs:sequence>
+		</xs:complexType>
+		<xs:key name="bookKey">
+			<xs:selector xpath="book" />
+			<xs:field xpath="@isbn" />
+		</xs:key>
+		<xs:keyref name="sequelRef" refer="bookKey">
+			<xs:selector xpath="book" />
+			<xs:field xpath="@sequel" />
+		</xs:keyref>
+		<xs:unique name="uniqueTitle">
+			<xs:selector xpath="book" />
+			<xs:field xpath="title" />
+		</xs:unique>
+	</xs:element>
+	<xs:element name="cover" type="xs:base64Binary" />
+</xs:schema>`
+
+	var out bytes.Buffer
+	if err := GenerateFrom(&out, strings.NewReader(schema), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Join(strings.Fields(out.String()), " ")
+	for _, exp := range []string{
+		"type library struct { XMLName xml.Name `xml:\"library\"` Book []book `xml:\"book\"` }",
+		"type book struct { Isbn string `xml:\"isbn,attr\"` Sequel string `xml:\"sequel,attr,omitempty\"` Title string `xml:\"title\"` }",
+		"type cover struct {",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Missing %q in the generated code", exp)
+		}
+	}
+	if t.Failed() {
+		t.Log(out.String())
+	}
+
+	reports, err := CheckFrom(strings.NewReader(schema), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) > 0 {
+		t.Errorf("Unexpected reports %q", reports)
+	}
+}
+
+// largeSchema returns a schema of n complex types, each with attributes and
+// with children of the types that follow it, used by a single root element.
+func largeSchema(n int) string {
+	var s strings.Builder
+	s.WriteString(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:large" targetNamespace="urn:large">
+	<xs:element name="root" type="tns:type0" />
+	<xs:simpleType name="status">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="active" />
+			<xs:enumeration value="inactive" />
+		</xs:restriction>
+	</xs:simpleType>
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&s, `	<xs:complexType name="type%d">
+		<xs:sequence>
+			<xs:element name="name" type="xs:string" />
+			<xs:element name="count" type="xs:int" minOccurs="0" />
+			<xs:element name="status" type="tns:status" />
+`, i)
+		for _, c := range []int{2*i + 1, 2*i + 2} {
+			if c < n {
+				fmt.Fprintf(&s, "\t\t\t<xs:element name=\"child%d\" type=\"tns:type%d\" minOccurs=\"0\" maxOccurs=\"unbounded\" />\n", c, c)
+			}
+		}
+		s.WriteString(`		</xs:sequence>
+		<xs:attribute name="id" type="xs:ID" use="required" />
+		<xs:attribute name="created" type="xs:dateTime" />
+	</xs:complexType>
+`)
+	}
+	s.WriteString("</xs:schema>\n")
+	return s.String()
+}
+
+func BenchmarkBuildXML(b *testing.B) {
+	schemas, err := parseXSDReader(strings.NewReader(largeSchema(5000)), true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := newBuilder(schemas).buildXML(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
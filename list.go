@@ -0,0 +1,124 @@
+package goxsd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Named slice type of the fields of simple list types, decoding and
+// encoding the whitespace separated values of the list, which encoding/xml
+// does not split, as text. With json tags, the list stays a JSON array.
+var list = `{{ define "List" }}
+{{ printf "// %s is a list of %s values, whitespace separated in XML.\n" .Type .Item }}{{ printf "type %s []%s\n" .Type .Item }}
+// UnmarshalText decodes the whitespace separated values of the list.
+{{ printf "func (l *%s) UnmarshalText(text []byte) error {\n" .Type }}{{ printf "var items %s\n" .Type }}for _, s := range strings.Fields(string(text)) {
+{{ with .Parse }}{{ . }}
+if err != nil {
+return err
+}
+{{ end }}{{ printf "items = append(items, %s)\n" .Value }}}
+*l = items
+return nil
+}
+
+// MarshalText encodes the list as whitespace separated values.
+{{ printf "func (l %s) MarshalText() ([]byte, error) {\n" .Type }}s := make([]string, len(l))
+for i, v := range l {
+{{ with .Format }}{{ printf "s[i] = %s\n" . }}{{ else }}b, err := v.MarshalText()
+if err != nil {
+return nil, err
+}
+s[i] = string(b)
+{{ end }}}
+return []byte(strings.Join(s, " ")), nil
+}
+{{ if .JSON }}
+// MarshalJSON encodes the list as a JSON array.
+{{ printf "func (l %s) MarshalJSON() ([]byte, error) {\n" .Type }}{{ printf "return json.Marshal([]%s(l))\n" .Item }}}
+
+// UnmarshalJSON decodes the list from a JSON array.
+{{ printf "func (l *%s) UnmarshalJSON(data []byte) error {\n" .Type }}{{ printf "return json.Unmarshal(data, (*[]%s)(l))\n" .Item }}}
+{{ end }}{{ end }}`
+
+// listData is the data of the named slice type of a simple list type.
+type listData struct {
+	Type string
+	Item string // Go type of the values
+	// Go statement parsing a value s into v, setting err, or empty if s
+	// needs no parsing
+	Parse string
+	Value string // Go expression of the value parsed from s
+	// Go expression formatting a value v as a string, or empty for values
+	// that marshal themselves as text
+	Format string
+	JSON   bool
+}
+
+// listType returns the name of the named slice type of a simple list type
+// with values of the given Go type, such as intList.
+func (g generator) listType(item string) string {
+	name := item[strings.LastIndex(item, ".")+1:]
+	switch item {
+	case "[]byte":
+		name = "bytes"
+	case dateType:
+		name = "date"
+	}
+	return g.typeName(strings.ToLower(name[:1]) + name[1:] + "List")
+}
+
+// listOf returns the data of the named slice type of a simple list type
+// with values of the given Go type.
+func (g generator) listOf(item string) listData {
+	l := listData{Type: g.listType(item), Item: g.useType(g.goType(item)), Value: "v", JSON: g.json}
+	g.used.add("strings")
+	if g.json {
+		g.used.add("encoding/json")
+	}
+	switch {
+	case item == "string":
+		l.Value, l.Format = "s", "v"
+	case item == "[]byte":
+		l.Value, l.Format = "[]byte(s)", "string(v)"
+	case item == "bool":
+		g.used.add("strconv")
+		l.Parse = "v, err := strconv.ParseBool(s)"
+		l.Format = "strconv.FormatBool(v)"
+	case strings.HasPrefix(item, "int") || item == "time.Duration":
+		// encoding/xml decodes a time.Duration as its integer nanoseconds
+		g.used.add("strconv")
+		l.Parse = fmt.Sprintf("v, err := strconv.ParseInt(s, 10, %d)", bitSize(item))
+		l.Value = item + "(v)"
+		l.Format = "strconv.FormatInt(int64(v), 10)"
+	case strings.HasPrefix(item, "uint"):
+		g.used.add("strconv")
+		l.Parse = fmt.Sprintf("v, err := strconv.ParseUint(s, 10, %d)", bitSize(item))
+		l.Value = item + "(v)"
+		l.Format = "strconv.FormatUint(uint64(v), 10)"
+	case strings.HasPrefix(item, "float"):
+		g.used.add("strconv")
+		l.Parse = fmt.Sprintf("v, err := strconv.ParseFloat(s, %d)", bitSize(item))
+		l.Value = item + "(v)"
+		l.Format = fmt.Sprintf("strconv.FormatFloat(float64(v), 'g', -1, %d)", bitSize(item))
+	default:
+		// time.Time, dates, and the types of Options.TypeMap, decode
+		// from and encode to text themselves
+		l.Parse = fmt.Sprintf("var v %s\nerr := v.UnmarshalText([]byte(s))", l.Item)
+	}
+	return l
+}
+
+// listItems returns the Go types of the values of the simple list fields of
+// the struct generated for e.
+func listItems(e *xmlTree) []string {
+	var items []string
+	if e.Cdata && e.SimpleList {
+		items = append(items, e.Type)
+	}
+	for _, c := range e.Children {
+		if simpleList(c) {
+			items = appendKey(items, c.Type)
+		}
+	}
+	return items
+}
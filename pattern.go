@@ -0,0 +1,222 @@
+package goxsd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Named string type generated from a simple type restricted by a pattern,
+// with Options.PatternTypes, along with its compiled pattern and a Validate
+// method
+var pattern = `{{ define "Pattern" }}{{ with patternValidation . }}{{ printf "// %s is generated from an XSD simple type with a pattern\n" .Type }}{{ with structDoc $ }}//
+{{ . }}{{ end }}{{ with source $ (structName $) }}//
+{{ . }}{{ end }}{{ printf "type %s string\n\n" .Type }}{{ printf "var %s = regexp.MustCompile(%q)\n\n" .Var .Regexp }}{{ printf "// Validate checks a %s against the pattern of its XSD type.\n" .Type }}{{ printf "func (v %s) Validate() error {\n" .Type }}{{ printf "if !%s.MatchString(string(v)) {\n" .Var }}{{ printf "return fmt.Errorf(%q, string(v))\n}\n" .Error }}return nil
+}
+{{ end }}{{ end }}`
+
+// patternValidation is the data of a pattern type.
+type patternValidation struct {
+	Type   string
+	Var    string // package variable with the compiled pattern
+	Regexp string // the pattern as a Go regular expression
+	Error  string
+}
+
+// patternValidation returns the data of the pattern type generated for e.
+func (g generator) patternValidation(e *xmlTree, typeName func(string) string) (patternValidation, error) {
+	p := patternValidation{Type: typeName(structName(e))}
+	re, err := goPatterns(e.Facets.Patterns, e.Name)
+	if err != nil {
+		return p, err
+	}
+	p.Var = lowerFirst(p.Type) + "Pattern"
+	p.Regexp = re
+	p.Error = fmt.Sprintf("%s: %%q does not match the pattern %s", p.Type, strings.Replace(strings.Join(e.Facets.Patterns, "|"), "%", "%%", -1))
+	g.used.add("fmt", "regexp")
+	return p, nil
+}
+
+// patternType reports whether a named string type with a Validate method is
+// generated for the element. Character data of an element with attributes
+// keeps its base type.
+func patternType(e *xmlTree) bool {
+	return e.PatternType && !e.Cdata
+}
+
+// goPatterns translates the patterns of the named simple type or value into
+// a Go regular expression of the whole values matching any of them.
+func goPatterns(patterns []string, name string) (string, error) {
+	var res []string
+	for _, pattern := range patterns {
+		re, err := goRegexp(pattern)
+		if err != nil {
+			return "", fmt.Errorf("pattern %q of %s: %w", pattern, name, err)
+		}
+		res = append(res, re)
+	}
+	if len(res) == 1 {
+		return "^(?:" + res[0] + ")$", nil
+	}
+	return "^(?:(?:" + strings.Join(res, ")|(?:") + "))$", nil
+}
+
+// xsdBlocks are the ranges of the Unicode blocks of XSD regular expressions,
+// such as \p{IsBasicLatin}, which Go has no escapes for.
+var xsdBlocks = map[string]string{
+	"BasicLatin":                 `\x00-\x7F`,
+	"Latin-1Supplement":          `\x80-\xFF`,
+	"LatinExtended-A":            `\x{100}-\x{17F}`,
+	"LatinExtended-B":            `\x{180}-\x{24F}`,
+	"IPAExtensions":              `\x{250}-\x{2AF}`,
+	"Greek":                      `\x{370}-\x{3FF}`,
+	"Cyrillic":                   `\x{400}-\x{4FF}`,
+	"Armenian":                   `\x{530}-\x{58F}`,
+	"Hebrew":                     `\x{590}-\x{5FF}`,
+	"Arabic":                     `\x{600}-\x{6FF}`,
+	"Devanagari":                 `\x{900}-\x{97F}`,
+	"Thai":                       `\x{E00}-\x{E7F}`,
+	"GeneralPunctuation":         `\x{2000}-\x{206F}`,
+	"CurrencySymbols":            `\x{20A0}-\x{20CF}`,
+	"Hiragana":                   `\x{3040}-\x{309F}`,
+	"Katakana":                   `\x{30A0}-\x{30FF}`,
+	"CJKUnifiedIdeographs":       `\x{4E00}-\x{9FFF}`,
+	"HangulSyllables":            `\x{AC00}-\x{D7AF}`,
+	"HalfwidthandFullwidthForms": `\x{FF00}-\x{FFEF}`,
+}
+
+// xsdClasses are the multi-character escapes of XSD regular expressions that
+// differ from those of Go, as the contents of a Go character class. Those of
+// the upper case escapes are negated.
+var xsdClasses = map[rune]string{
+	'i': `\p{L}_:`,
+	'c': `\p{L}\p{M}\p{Nd}._:\-`,
+	'd': `\p{Nd}`,
+	'w': `^\p{P}\p{Z}\p{C}`,
+}
+
+// goRegexp translates an XSD regular expression into a Go one, matching
+// the same strings once anchored. XSD expressions are always anchored, so ^
+// and $ are literals in them, \d and \w are not limited to ASCII, and the
+// name character escapes \i and \c and the block escapes \p{IsBlock} have
+// no Go equivalent. Go classes cannot hold negated ones, so a character class
+// with negated escapes, such as [\w.-], becomes an alternation of the class
+// and the negated ones. Character class subtraction, and negated escapes
+// within a negated class, are not supported.
+func goRegexp(pattern string) (string, error) {
+	var out, class bytes.Buffer
+	w := &out // class while inClass
+	inClass, negatedClass := false, false
+	var negated []string // negated escapes of the class
+	rs := []rune(pattern)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs):
+			i++
+			esc := rs[i]
+			switch lower := esc | 0x20; {
+			case esc == 'p' || esc == 'P':
+				rest := string(rs[i+1:])
+				end := strings.Index(rest, "}")
+				if !strings.HasPrefix(rest, "{") || end < 0 {
+					return "", fmt.Errorf(`\%c without a {property}`, esc)
+				}
+				name := rest[1:end]
+				i += len([]rune(name)) + 2
+				if !strings.HasPrefix(name, "Is") {
+					fmt.Fprintf(w, `\%c{%s}`, esc, name)
+					break
+				}
+				block, ok := xsdBlocks[name[2:]]
+				switch {
+				case !ok:
+					return "", fmt.Errorf("unknown Unicode block %s", name[2:])
+				case inClass && esc == 'P':
+					negated = append(negated, block)
+				case inClass:
+					class.WriteString(block)
+				case esc == 'P':
+					fmt.Fprintf(&out, "[^%s]", block)
+				default:
+					fmt.Fprintf(&out, "[%s]", block)
+				}
+			case xsdClasses[lower] != "":
+				chars := xsdClasses[lower]
+				neg := esc != lower
+				if strings.HasPrefix(chars, "^") {
+					chars, neg = chars[1:], !neg
+				}
+				switch {
+				case inClass && neg:
+					negated = append(negated, chars)
+				case inClass:
+					class.WriteString(chars)
+				case neg:
+					fmt.Fprintf(&out, "[^%s]", chars)
+				default:
+					fmt.Fprintf(&out, "[%s]", chars)
+				}
+			default:
+				w.WriteRune('\\')
+				w.WriteRune(esc)
+			}
+		case r == '[' && inClass:
+			return "", fmt.Errorf("character class subtraction is not supported")
+		case r == '[':
+			inClass, w = true, &class
+			negatedClass = i+1 < len(rs) && rs[i+1] == '^'
+			if negatedClass {
+				i++
+			}
+		case r == ']' && inClass:
+			if err := writeClass(&out, class.String(), negatedClass, negated); err != nil {
+				return "", err
+			}
+			inClass, w = false, &out
+			class.Reset()
+			negated = nil
+		case (r == '^' || r == '$') && !inClass:
+			out.WriteRune('\\')
+			out.WriteRune(r)
+		case r == '.' && !inClass:
+			out.WriteString(`[^\n\r]`)
+		default:
+			w.WriteRune(r)
+		}
+	}
+	if inClass {
+		// Left for regexp.Compile to report
+		fmt.Fprintf(&out, "[%s", class.String())
+	}
+	if _, err := regexp.Compile(out.String()); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// writeClass writes a translated character class holding the given
+// characters, and the contents of the given negated classes, to out.
+func writeClass(out *bytes.Buffer, chars string, negatedClass bool, negated []string) error {
+	switch {
+	case len(negated) == 0 && negatedClass:
+		fmt.Fprintf(out, "[^%s]", chars)
+	case len(negated) == 0:
+		fmt.Fprintf(out, "[%s]", chars)
+	case negatedClass:
+		return fmt.Errorf("negated escapes within a negated character class are not supported")
+	case chars == "" && len(negated) == 1:
+		fmt.Fprintf(out, "[^%s]", negated[0])
+	default:
+		var alts []string
+		if chars != "" {
+			alts = append(alts, "["+chars+"]")
+		}
+		for _, n := range negated {
+			alts = append(alts, "[^"+n+"]")
+		}
+		fmt.Fprintf(out, "(?:%s)", strings.Join(alts, "|"))
+	}
+	return nil
+}
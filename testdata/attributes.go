@@ -0,0 +1,22 @@
+// Code generated by goxsd from attributes.xsd; DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// setting is generated from an XSD element
+type setting struct {
+	XMLName xml.Name `xml:"setting"`
+	Key     string   `xml:"key,attr"`
+	Value   string   `xml:"value,attr,omitempty"`
+	// Defaults to "0" when absent.
+	Priority int32 `xml:"priority,attr,omitempty"`
+	// Fixed to "1.0" by the schema.
+	Version   string    `xml:"version,attr,omitempty"`
+	Enabled   bool      `xml:"enabled,attr,omitempty"`
+	ChangedBy string    `xml:"changed-by,attr,omitempty"`
+	ChangedAt time.Time `xml:"changed-at,attr,omitempty"`
+}
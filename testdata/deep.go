@@ -0,0 +1,41 @@
+// Code generated by goxsd from deep.xsd; DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/xml"
+)
+
+// catalog is generated from an XSD element
+type catalog struct {
+	XMLName xml.Name  `xml:"catalog"`
+	Section []section `xml:"section"`
+}
+
+// section is generated from an XSD element
+type section struct {
+	Title   string        `xml:"title,attr,omitempty"`
+	Product []productType `xml:"product"`
+}
+
+// productType is generated from an XSD element
+type productType struct {
+	Variant []variant `xml:"variant,omitempty"`
+}
+
+// variant is generated from an XSD element
+type variant struct {
+	Sku   string `xml:"sku,attr"`
+	Stock stock  `xml:"stock"`
+}
+
+// stock is generated from an XSD element
+type stock struct {
+	Count    uint      `xml:"count,attr"`
+	Location *location `xml:"location,omitempty"`
+}
+
+// location is generated from an XSD element
+type location struct {
+	Shelf string `xml:"shelf,attr"`
+}
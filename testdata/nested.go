@@ -0,0 +1,59 @@
+// Code generated by goxsd from nested.xsd; DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// company is generated from an XSD element
+type company struct {
+	XMLName    xml.Name     `xml:"company"`
+	Department []department `xml:"department"`
+}
+
+// department is generated from an XSD element
+type department struct {
+	Name     string     `xml:"name,attr"`
+	Employee []employee `xml:"employee"`
+}
+
+// employee is generated from an XSD element
+type employee struct {
+	ID    string  `xml:"id,attr"`
+	Name  string  `xml:"name"`
+	Start xsdDate `xml:"start"`
+}
+
+// xsdDate is an xsd:date, such as 2006-01-02, at midnight of the day. The time
+// zone of the date, if it has one, is kept in its location.
+type xsdDate struct {
+	time.Time
+}
+
+// UnmarshalText decodes a date, with or without a time zone.
+func (d *xsdDate) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	layout := "2006-01-02"
+	if len(s) > len(layout) {
+		layout += "Z07:00"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// MarshalText encodes the date without its time zone.
+func (d xsdDate) MarshalText() ([]byte, error) {
+	return []byte(d.Format("2006-01-02")), nil
+}
+
+// String returns the date without its time zone, as MarshalText encodes it.
+func (d xsdDate) String() string {
+	return d.Format("2006-01-02")
+}
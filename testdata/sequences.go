@@ -0,0 +1,27 @@
+// Code generated by goxsd from sequences.xsd; DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/xml"
+)
+
+// library is generated from an XSD element
+type library struct {
+	XMLName xml.Name   `xml:"library"`
+	Name    string     `xml:"name"`
+	Book    []bookType `xml:"book,omitempty"`
+}
+
+// bookType is generated from an XSD element
+//
+// A book on the shelves of the library.
+type bookType struct {
+	// Allowed values: fiction, non-fiction.
+	Genre     string   `xml:"genre,attr,omitempty"`
+	Title     string   `xml:"title"`
+	Author    []string `xml:"author"`
+	Isbn      *string  `xml:"isbn,omitempty"`
+	Paperback *bool    `xml:"paperback,omitempty"`
+	EbookURL  *string  `xml:"ebook-url,omitempty"`
+}
@@ -0,0 +1,26 @@
+// Code generated by goxsd from simplecontent.xsd; DO NOT EDIT.
+
+package golden
+
+import (
+	"encoding/xml"
+)
+
+// invoice is generated from an XSD element
+type invoice struct {
+	XMLName xml.Name   `xml:"invoice"`
+	Total   amountType `xml:"total"`
+	Note    *noteType  `xml:"note,omitempty"`
+}
+
+// amountType is generated from an XSD element
+type amountType struct {
+	Currency string  `xml:"currency,attr"`
+	Value    float64 `xml:",chardata"`
+}
+
+// noteType is generated from an XSD element
+type noteType struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
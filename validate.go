@@ -0,0 +1,167 @@
+package goxsd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Validate method of a struct with values constrained by pattern or length
+// facets, generated with Options.Validate
+var validate = `{{ define "Validate" }}{{ with validation . }}{{ range $c := .Checks }}{{ with $c.Var }}{{ printf "var %s = regexp.MustCompile(%q)\n" . $c.Regexp }}{{ end }}{{ end }}
+{{ printf "// Validate checks the values of a %s against the pattern and length\n" .Type }}// facets of their XSD types.
+{{ printf "func (v %s) Validate() error {\n" .Type }}{{ range $c := .Checks }}{{ $c.Open }}{{ with $c.Var }}{{ printf "if !%s.MatchString(%s) {\n" . $c.Value }}{{ printf "return fmt.Errorf(%q, %s)\n}\n" $c.PatternError $c.Value }}{{ end }}{{ with $c.Length }}{{ printf "if n := utf8.RuneCountInString(%s); %s {\n" $c.Value . }}{{ printf "return fmt.Errorf(%q, n)\n}\n" $c.LengthError }}{{ end }}{{ $c.Close }}{{ end }}return nil
+}
+{{ end }}{{ end }}`
+
+// validation is the data of the Validate method of a struct.
+type validation struct {
+	Type   string
+	Checks []valueCheck
+}
+
+// valueCheck checks the facets of one field of a struct.
+type valueCheck struct {
+	Value       string // Go expression of the checked value
+	Open, Close string // code around the checks, for optional and repeated fields
+
+	Var          string // package variable with the compiled pattern
+	Regexp       string // the pattern as a Go regular expression
+	PatternError string
+
+	Length      string // condition on the length n that fails the check
+	LengthError string
+}
+
+// validation returns the checks of the Validate method of the struct
+// generated for e. Only string values are checked, as the facets constrain
+// the lexical form, which other types no longer have once decoded.
+func (g generator) validation(e *xmlTree, typeName func(string) string) (validation, error) {
+	v := validation{Type: typeName(structName(e))}
+	// add adds the checks of a value, which an open line such as an if
+	// statement or a loop may introduce
+	add := func(name, field string, f *xmlFacets, value, open string) error {
+		c := valueCheck{Value: value, Open: open}
+		if open != "" {
+			c.Close = "}\n"
+		}
+
+		if len(f.Patterns) > 0 {
+			re, err := goPatterns(f.Patterns, name)
+			if err != nil {
+				return err
+			}
+			c.Regexp = re
+			c.Var = lowerFirst(v.Type) + field + "Pattern"
+			c.PatternError = fmt.Sprintf("%s: %%q does not match the pattern %s", name, strings.Replace(strings.Join(f.Patterns, "|"), "%", "%%", -1))
+		}
+
+		switch {
+		case f.MinLength == f.MaxLength:
+			c.Length = fmt.Sprintf("n != %d", f.MinLength)
+			c.LengthError = fmt.Sprintf("%s: length %%d is not %d", name, f.MinLength)
+		case f.MaxLength < 0 && f.MinLength > 0:
+			c.Length = fmt.Sprintf("n < %d", f.MinLength)
+			c.LengthError = fmt.Sprintf("%s: length %%d is less than %d", name, f.MinLength)
+		case f.MaxLength >= 0 && f.MinLength == 0:
+			c.Length = fmt.Sprintf("n > %d", f.MaxLength)
+			c.LengthError = fmt.Sprintf("%s: length %%d is more than %d", name, f.MaxLength)
+		case f.MaxLength >= 0:
+			c.Length = fmt.Sprintf("n < %d || n > %d", f.MinLength, f.MaxLength)
+			c.LengthError = fmt.Sprintf("%s: length %%d is not between %d and %d", name, f.MinLength, f.MaxLength)
+		}
+		v.Checks = append(v.Checks, c)
+		return nil
+	}
+
+	for i, a := range e.Attribs {
+		if !validatedAttr(a) {
+			continue
+		}
+		// Optional attributes are left empty when absent
+		field := g.attrField(e, i).Field
+		value, open := "v."+field, ""
+		if g.attrPointer(a) {
+			value, open = "*v."+field, fmt.Sprintf("if v.%s != nil {\n", field)
+		} else if a.Optional {
+			open = fmt.Sprintf("if v.%s != \"\" {\n", field)
+		}
+		if err := add(a.Name, field, a.Facets, value, open); err != nil {
+			return v, err
+		}
+	}
+	for _, c := range e.Children {
+		if !validatedChild(c) {
+			continue
+		}
+		field := g.childField(c)
+		value, open := "v."+field, ""
+		if c.List {
+			value, open = "s", fmt.Sprintf("for _, s := range v.%s {\n", field)
+		} else if g.childPointer(c) {
+			value, open = "*v."+field, fmt.Sprintf("if v.%s != nil {\n", field)
+		}
+		if err := add(c.Name, field, c.Facets, value, open); err != nil {
+			return v, err
+		}
+	}
+	if validatedCdata(e) {
+		field := g.cdataField(e)
+		if err := add(e.Name, field, e.Facets, "v."+field, ""); err != nil {
+			return v, err
+		}
+	}
+
+	for _, c := range v.Checks {
+		g.used.add("fmt")
+		if c.Var != "" {
+			g.used.add("regexp")
+		}
+		if c.Length != "" {
+			g.used.add("unicode/utf8")
+		}
+	}
+	return v, nil
+}
+
+// validated reports whether the struct generated for e gets a Validate
+// method.
+func validated(e *xmlTree) bool {
+	if enumType(e) {
+		return false
+	}
+	for _, a := range e.Attribs {
+		if validatedAttr(a) {
+			return true
+		}
+	}
+	for _, c := range e.Children {
+		if validatedChild(c) {
+			return true
+		}
+	}
+	return validatedCdata(e)
+}
+
+func validatedAttr(a xmlAttrib) bool {
+	return a.Facets != nil && a.Type == "string"
+}
+
+// validatedChild reports whether a child is checked by the Validate method
+// of its parent. Children with a type of their own are checked by theirs,
+// and the elements of a choice interface are not fields of their parent.
+func validatedChild(c *xmlTree) bool {
+	return !c.Choice && c.Facets != nil && c.Type == "string" && !c.SimpleList && primitiveType(c)
+}
+
+func validatedCdata(e *xmlTree) bool {
+	return e.Cdata && e.Facets != nil && e.Type == "string"
+}
+
+// lowerFirst lower cases the first letter of an identifier, so that it is
+// unexported.
+func lowerFirst(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[n:]
+}
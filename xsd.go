@@ -1,62 +1,292 @@
-package main
+package goxsd
 
 import (
+	"bytes"
 	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
-var (
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// parser keeps track of the schemas parsed by a single parseXSDFile.
+type parser struct {
 	parsedFiles map[string]struct{}
-)
 
-func parseXSDFile(fname string) ([]xsdSchema, error) {
-	schemas := []xsdSchema{}
-	parsedFiles = make(map[string]struct{})
-	schemas, err := parse(fname)
+	// noNetwork disables fetching schemas with http(s) locations
+	noNetwork bool
+}
+
+func parseXSDFile(fname string, noNetwork bool) ([]xsdSchema, error) {
+	p := parser{parsedFiles: make(map[string]struct{}), noNetwork: noNetwork}
+	schemas, err := p.parse(fname)
 	if err != nil {
 		return nil, err
 	}
 	return schemas, nil
 }
 
-func parse(fname string) ([]xsdSchema, error) {
-	f, err := os.Open(fname)
+// parseXSDDir parses every XSD and WSDL file in dir, and in its subdirectories if
+// recursive, in lexical order, along with the schemas they import. Schemas
+// that are imported by another are parsed once.
+func parseXSDDir(dir string, recursive, noNetwork bool) ([]xsdSchema, error) {
+	p := parser{parsedFiles: make(map[string]struct{}), noNetwork: noNetwork}
+	var schemas []xsdSchema
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); !strings.EqualFold(ext, ".xsd") && !strings.EqualFold(ext, ".wsdl") {
+			return nil
+		}
+		s, err := p.parse(path)
+		schemas = append(schemas, s...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("no XSD files in %s", dir)
+	}
+	return schemas, nil
+}
 
-	var schema xsdSchema
-	if err := xml.NewDecoder(f).Decode(&schema); err != nil {
+// stdinLocation names a schema read from a reader rather than a location.
+// Its relative imports resolve against the working directory.
+const stdinLocation = "<stdin>"
+
+// parseXSDReader parses the schema read from r, such as stdin, and the
+// schemas it imports.
+func parseXSDReader(r io.Reader, noNetwork bool) ([]xsdSchema, error) {
+	p := parser{parsedFiles: make(map[string]struct{}), noNetwork: noNetwork}
+	return p.parseReader(r, stdinLocation)
+}
+
+// parse parses an XSD file, or a schema at an http(s) URL, and recursively
+// the schemas it imports or includes. Every schema is parsed once,
+// identified by its absolute path or URL, so that cyclic, diamond and self
+// imports are harmless.
+func (p parser) parse(loc string) ([]xsdSchema, error) {
+	if !isURL(loc) {
+		path, err := filepath.Abs(loc)
+		if err != nil {
+			return nil, err
+		}
+		loc = path
+	}
+	if _, ok := p.parsedFiles[loc]; ok {
+		return nil, nil
+	}
+	p.parsedFiles[loc] = struct{}{}
+
+	r, err := p.openSchema(loc)
+	if err != nil {
 		return nil, err
 	}
-	f.Close()
+	defer r.Close()
+	return p.parseReader(r, loc)
+}
 
-	schemas := []xsdSchema{schema}
-	dir, file := filepath.Split(fname)
-	parsedFiles[file] = struct{}{}
-	for _, imp := range schema.Imports {
-		if _, ok := parsedFiles[imp.Location]; ok {
-			continue
+// parseReader parses the schema read from r, which is located at loc, and
+// the schemas it imports or includes.
+func (p parser) parseReader(r io.Reader, loc string) ([]xsdSchema, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rootName(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema '%s': %w", schemaName(loc), err)
+	}
+
+	var doc []xsdSchema
+	if wsdlRoot(root) {
+		if doc, err = wsdlSchemas(data); err != nil {
+			return nil, fmt.Errorf("parsing WSDL '%s': %w", schemaName(loc), err)
 		}
-		s, err := parse(filepath.Join(dir, imp.Location))
-		if err != nil {
-			return nil, err
+		if len(doc) == 0 {
+			return nil, fmt.Errorf("no schemas in the types of WSDL %s", loc)
+		}
+	} else {
+		var schema xsdSchema
+		if err := xml.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("parsing schema '%s': %w", schemaName(loc), err)
+		}
+		doc = []xsdSchema{schema}
+	}
+	// The document is checked once, through its first schema
+	doc[0].data = data
+
+	var schemas []xsdSchema
+	for _, schema := range doc {
+		schema.loc = loc
+		qualifyRefs(&schema)
+		schemas = append(schemas, schema)
+
+		imps := append(schema.Imports, schema.Includes...)
+		for _, r := range schema.Redefines {
+			imps = append(imps, xsdImport{Location: r.Location})
+		}
+		for _, imp := range imps {
+			// An import may only name a namespace, without a location,
+			// such as that of another schema of the same WSDL
+			if imp.Location == "" {
+				continue
+			}
+			s, err := p.parse(resolveLocation(loc, imp.Location))
+			if err != nil {
+				return nil, fmt.Errorf("importing '%s' from schema '%s': %w", imp.Location, schemaName(loc), err)
+			}
+			schemas = append(schemas, s...)
 		}
-		schemas = append(schemas, s...)
 	}
 	return schemas, nil
 }
 
+// rootName returns the name of the root element of an XML document.
+func rootName(data []byte) (xml.Name, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if t, ok := tok.(xml.StartElement); ok {
+			return t.Name, nil
+		}
+	}
+}
+
+// The namespaces of WSDL 1.1 and 2.0 documents.
+const (
+	wsdl11Namespace = "http://schemas.xmlsoap.org/wsdl/"
+	wsdl20Namespace = "http://www.w3.org/ns/wsdl"
+)
+
+// wsdlRoot reports whether the root element of a document is that of a
+// WSDL 1.1 definitions or a WSDL 2.0 description, which embed their schemas
+// in their types element.
+func wsdlRoot(name xml.Name) bool {
+	return name.Space == wsdl11Namespace && name.Local == "definitions" ||
+		name.Space == wsdl20Namespace && name.Local == "description"
+}
+
+// wsdlDefinitions is the root element of a WSDL document, of which only the
+// embedded schemas are read. The services, messages and bindings are no
+// concern of the generated types.
+type wsdlDefinitions struct {
+	Ns      string      `xml:"xmlns,attr"`
+	Attrs   []xml.Attr  `xml:",any,attr"`
+	Schemas []xsdSchema `xml:"types>schema"`
+}
+
+// wsdlSchemas returns the schemas embedded in a WSDL document. The
+// namespace prefixes declared by the root element are in scope of every
+// schema, unless the schema declares them again.
+func wsdlSchemas(data []byte) ([]xsdSchema, error) {
+	var defs wsdlDefinitions
+	if err := xml.Unmarshal(data, &defs); err != nil {
+		return nil, err
+	}
+	for i := range defs.Schemas {
+		s := &defs.Schemas[i]
+		if s.Ns == "" {
+			s.Ns = defs.Ns
+		}
+		declared := make(map[string]bool)
+		for _, a := range s.Attrs {
+			if a.Name.Space == "xmlns" {
+				declared[a.Name.Local] = true
+			}
+		}
+		for _, a := range defs.Attrs {
+			if a.Name.Space == "xmlns" && !declared[a.Name.Local] {
+				s.Attrs = append(s.Attrs, a)
+			}
+		}
+	}
+	return defs.Schemas, nil
+}
+
+// openSchema opens the schema at a file path or an http(s) URL.
+func (p parser) openSchema(loc string) (io.ReadCloser, error) {
+	if !isURL(loc) {
+		return os.Open(loc)
+	}
+	if p.noNetwork {
+		return nil, fmt.Errorf("cannot fetch schema %s: network access is disabled", loc)
+	}
+
+	resp, err := httpClient.Get(loc)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot fetch schema %s: %s", loc, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// resolveLocation resolves the location of an imported schema relative to
+// the location of the importing schema.
+func resolveLocation(base, loc string) string {
+	if isURL(loc) {
+		return loc
+	}
+	if isURL(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return loc
+		}
+		r, err := url.Parse(loc)
+		if err != nil {
+			return loc
+		}
+		return b.ResolveReference(r).String()
+	}
+	return filepath.Join(filepath.Dir(base), loc)
+}
+
+func isURL(loc string) bool {
+	return strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://")
+}
+
 // xsdSchema is the Go representation of an XSD schema.
 type xsdSchema struct {
-	XMLName      xml.Name
-	Ns           string           `xml:"xmlns,attr"`
-	Imports      []xsdImport      `xml:"import"`
-	Elements     []xsdElement     `xml:"element"`
-	ComplexTypes []xsdComplexType `xml:"complexType"`
-	SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+	XMLName         xml.Name
+	Ns              string              `xml:"xmlns,attr"`
+	TargetNs        string              `xml:"targetNamespace,attr"`
+	Imports         []xsdImport         `xml:"import"`
+	Includes        []xsdImport         `xml:"include"`
+	Elements        []xsdElement        `xml:"element"`
+	ComplexTypes    []xsdComplexType    `xml:"complexType"`
+	SimpleTypes     []xsdSimpleType     `xml:"simpleType"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	Groups          []xsdGroup          `xml:"group"`
+	Redefines       []xsdRedefine       `xml:"redefine"`
+	Notations       []xsdIgnored        `xml:"notation"`
+	Attrs           []xml.Attr          `xml:",any,attr"` // including xmlns:* declarations
+
+	loc  string // path or URL the schema was parsed from
+	data []byte // the schema document
 }
 
 // ns parses the namespace from a value in the expected format
@@ -69,12 +299,117 @@ func (s xsdSchema) ns() string {
 	return ""
 }
 
+// refAttrs are the attributes of XSD elements whose values are QNames
+// referring to a definition.
+var refAttrs = map[string]bool{
+	"type":              true,
+	"base":              true,
+	"ref":               true,
+	"itemType":          true,
+	"substitutionGroup": true,
+}
+
+// qualifyRefs rewrites the references of a schema to its definitions into
+// {namespace}local form, resolving their prefixes by the namespace
+// declarations of the schema element. Unprefixed references are in the
+// default namespace, if declared. References with undeclared prefixes are
+// left as they are, and are resolved by local name alone.
+func qualifyRefs(s *xsdSchema) {
+	prefixes := map[string]string{"": s.Ns}
+	for _, a := range s.Attrs {
+		if a.Name.Space == "xmlns" {
+			prefixes[a.Name.Local] = a.Value
+		}
+	}
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Type().Field(i)
+				if f.PkgPath != "" {
+					continue
+				}
+				tag := strings.Split(f.Tag.Get("xml"), ",")
+				if f.Type.Kind() == reflect.String && len(tag) > 1 && tag[1] == "attr" && refAttrs[tag[0]] {
+					v.Field(i).SetString(qualify(v.Field(i).String(), prefixes))
+					continue
+				}
+				walk(v.Field(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(s).Elem())
+}
+
+// qualify returns a QName in {namespace}local form, if its namespace is
+// known.
+func qualify(name string, prefixes map[string]string) string {
+	if name == "" || strings.HasPrefix(name, "{") {
+		return name
+	}
+	prefix, local := "", name
+	if i := strings.Index(name, ":"); i >= 0 {
+		prefix, local = name[:i], name[i+1:]
+	}
+	ns, ok := prefixes[prefix]
+	if !ok || ns == "" {
+		return name
+	}
+	return "{" + ns + "}" + local
+}
+
+// splitQName splits a QName into its namespace, if in {namespace}local
+// form, and its local name.
+func splitQName(name string) (ns, local string) {
+	if strings.HasPrefix(name, "{") {
+		if i := strings.Index(name, "}"); i > 0 {
+			return name[1:i], name[i+1:]
+		}
+	}
+	return "", stripNamespace(name)
+}
+
+// xsdIgnored is an XSD construct that is recognized, but has no bearing on
+// the generated code, such as a notation or an identity constraint. Its
+// content is not checked.
+type xsdIgnored struct {
+	Name string `xml:"name,attr"`
+}
+
 type xsdImport struct {
 	Location string `xml:"schemaLocation,attr"`
 }
 
+// xsdRedefine includes a schema like xsdImport, replacing some of its
+// definitions. A redefinition derives from, or refers to, the definition it
+// replaces by its own name.
+type xsdRedefine struct {
+	Location        string              `xml:"schemaLocation,attr"`
+	ComplexTypes    []xsdComplexType    `xml:"complexType"`
+	SimpleTypes     []xsdSimpleType     `xml:"simpleType"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	Groups          []xsdGroup          `xml:"group"`
+}
+
 type xsdElement struct {
-	Name        string          `xml:"name,attr"`
+	Name        string `xml:"name,attr"`
+	Ref         string `xml:"ref,attr"`
+	Abstract    bool   `xml:"abstract,attr"`
+	Nillable    bool   `xml:"nillable,attr"`
+	Substitutes string `xml:"substitutionGroup,attr"` // head element
+
+	ns          string          // target namespace of a top-level element
+	pos         int64           // offset in its schema
 	Type        string          `xml:"type,attr"`
 	Default     string          `xml:"default,attr"`
 	Min         string          `xml:"minOccurs,attr"`
@@ -82,24 +417,82 @@ type xsdElement struct {
 	Annotation  string          `xml:"annotation>documentation"`
 	ComplexType *xsdComplexType `xml:"complexType"` // inline complex type
 	SimpleType  *xsdSimpleType  `xml:"simpleType"`  // inline simple type
+
+	// Hints to code generators, among which goxsd directives
+	AppInfo []string `xml:"annotation>appinfo"`
+
+	// Identity constraints restrict values within a document, which the
+	// generated types cannot express
+	Keys    []xsdIgnored `xml:"key"`
+	KeyRefs []xsdIgnored `xml:"keyref"`
+	Uniques []xsdIgnored `xml:"unique"`
 }
 
+// UnmarshalXML decodes an element declaration, recording its offset in the
+// schema, so that the particles of a sequence can be built in document
+// order.
+func (e *xsdElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	pos := d.InputOffset()
+	type plain xsdElement
+	if err := d.DecodeElement((*plain)(e), &start); err != nil {
+		return err
+	}
+	e.pos = pos
+	return nil
+}
+
+// isList reports whether the element may occur more than once.
 func (e xsdElement) isList() bool {
-	return e.Max == "unbounded"
+	return occursMany(e.Max)
+}
+
+// occursMany reports whether a maxOccurs allows more than one occurrence.
+func occursMany(max string) bool {
+	switch max {
+	case "unbounded":
+		return true
+	case "", "1":
+		return false
+	}
+	n, err := strconv.Atoi(max)
+	return err == nil && n > 1
+}
+
+func (e xsdElement) isOptional() bool {
+	return e.Min == "0"
 }
 
+// inlineType reports whether an element is of the type declared inside it,
+// or of anyType if it declares none. An element may not both name a type and
+// declare one, but if it does, the declared type is the more specific one,
+// often restricting the named type with facets, and takes precedence.
 func (e xsdElement) inlineType() bool {
-	return e.Type == ""
+	return e.Type == "" || e.ComplexType != nil || e.SimpleType != nil
 }
 
 type xsdComplexType struct {
-	Name           string             `xml:"name,attr"`
-	Abstract       string             `xml:"abstract,attr"`
-	Annotation     string             `xml:"annotation>documentation"`
-	Sequence       []xsdElement       `xml:"sequence>element"`
-	Attributes     []xsdAttribute     `xml:"attribute"`
-	ComplexContent *xsdComplexContent `xml:"complexContent"`
-	SimpleContent  *xsdSimpleContent  `xml:"simpleContent"`
+	Name            string              `xml:"name,attr"`
+	Abstract        bool                `xml:"abstract,attr"` // only a base of other types
+	Mixed           bool                `xml:"mixed,attr"`
+	Annotation      string              `xml:"annotation>documentation"`
+	Sequence        []xsdElement        `xml:"sequence>element"`
+	SequenceChoice  []xsdElement        `xml:"sequence>choice>element"`
+	SequenceGroups  []xsdGroup          `xml:"sequence>group"`
+	Choice          xsdChoice           `xml:"choice"`
+	All             []xsdElement        `xml:"all>element"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	AnyAttribute    *xsdAnyAttribute    `xml:"anyAttribute"`
+	ComplexContent  *xsdComplexContent  `xml:"complexContent"`
+	SimpleContent   *xsdSimpleContent   `xml:"simpleContent"`
+}
+
+// xsdChoice is the choice that is the content of a complex type, or of its
+// extension or restriction. A choice that may occur more than once allows
+// any number of its elements, in any order.
+type xsdChoice struct {
+	Max      string       `xml:"maxOccurs,attr"`
+	Elements []xsdElement `xml:"element"`
 }
 
 type xsdComplexContent struct {
@@ -113,34 +506,121 @@ type xsdSimpleContent struct {
 }
 
 type xsdExtension struct {
-	Base       string         `xml:"base,attr"`
-	Attributes []xsdAttribute `xml:"attribute"`
-	Sequence   []xsdElement   `xml:"sequence>element"`
+	Base            string              `xml:"base,attr"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	AnyAttribute    *xsdAnyAttribute    `xml:"anyAttribute"`
+	Sequence        []xsdElement        `xml:"sequence>element"`
+	SequenceChoice  []xsdElement        `xml:"sequence>choice>element"`
+	SequenceGroups  []xsdGroup          `xml:"sequence>group"`
+	Choice          xsdChoice           `xml:"choice"`
+	All             []xsdElement        `xml:"all>element"`
 }
 
 type xsdAttribute struct {
 	Name       string `xml:"name,attr"`
+	Ref        string `xml:"ref,attr"` // top-level attribute, declared elsewhere
 	Type       string `xml:"type,attr"`
 	Use        string `xml:"use,attr"`
+	Default    string `xml:"default,attr"`
+	Fixed      string `xml:"fixed,attr"`
 	Annotation string `xml:"annotation>documentation"`
 }
 
+// isOptional reports whether the attribute may be left out, which is the
+// default when no use is given.
+func (a xsdAttribute) isOptional() bool {
+	return a.Use == "" || a.Use == "optional"
+}
+
+// xsdAnyAttribute allows attributes that the schema does not declare.
+type xsdAnyAttribute struct {
+	Namespace string `xml:"namespace,attr"`
+}
+
+// xsdAttributeGroup is either a named attribute group declared at the top of
+// a schema, or a reference to one.
+type xsdAttributeGroup struct {
+	Name            string              `xml:"name,attr"`
+	Ref             string              `xml:"ref,attr"`
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+}
+
+// xsdGroup is either a named model group declared at the top of a schema,
+// or a reference to one from within a sequence.
+type xsdGroup struct {
+	Name           string       `xml:"name,attr"`
+	Ref            string       `xml:"ref,attr"`
+	Sequence       []xsdElement `xml:"sequence>element"`
+	SequenceGroups []xsdGroup   `xml:"sequence>group"`
+	Choice         []xsdElement `xml:"choice>element"`
+	All            []xsdElement `xml:"all>element"`
+
+	pos int64 // offset in its schema
+}
+
+// UnmarshalXML is like that of xsdElement.
+func (g *xsdGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	pos := d.InputOffset()
+	type plain xsdGroup
+	if err := d.DecodeElement((*plain)(g), &start); err != nil {
+		return err
+	}
+	g.pos = pos
+	return nil
+}
+
 type xsdSimpleType struct {
-	Name        string         `xml:"name,attr"`
-	Annotation  string         `xml:"annotation>documentation"`
-	Restriction xsdRestriction `xml:"restriction"`
+	Name        string          `xml:"name,attr"`
+	Annotation  string          `xml:"annotation>documentation"`
+	Restriction *xsdRestriction `xml:"restriction"`
+	Union       *xsdUnion       `xml:"union"`
+	List        *xsdList        `xml:"list"`
 }
 
+// xsdRestriction derives a type by restricting its base. Simple types are
+// restricted by facets, while a restriction of complex content declares the
+// whole content model of the derived type.
 type xsdRestriction struct {
 	Base        string           `xml:"base,attr"`
-	Pattern     xsdPattern       `xml:"pattern"`
+	Patterns    []xsdPattern     `xml:"pattern"`
 	Enumeration []xsdEnumeration `xml:"enumeration"`
+	Length      *xsdFacet        `xml:"length"`
+	MinLength   *xsdFacet        `xml:"minLength"`
+	MaxLength   *xsdFacet        `xml:"maxLength"`
+
+	Attributes      []xsdAttribute      `xml:"attribute"`
+	AttributeGroups []xsdAttributeGroup `xml:"attributeGroup"`
+	AnyAttribute    *xsdAnyAttribute    `xml:"anyAttribute"`
+	Sequence        []xsdElement        `xml:"sequence>element"`
+	SequenceChoice  []xsdElement        `xml:"sequence>choice>element"`
+	SequenceGroups  []xsdGroup          `xml:"sequence>group"`
+	Choice          xsdChoice           `xml:"choice"`
+	All             []xsdElement        `xml:"all>element"`
+}
+
+// xsdUnion is a simple type whose values may be of any of its member types.
+type xsdUnion struct {
+	MemberTypes string `xml:"memberTypes,attr"`
+}
+
+// xsdList is a simple type whose values are whitespace separated lists of
+// its item type, given either by name or as an inline simple type.
+type xsdList struct {
+	ItemType   string         `xml:"itemType,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"`
 }
 
 type xsdPattern struct {
 	Value string `xml:"value,attr"`
 }
 
+// xsdFacet is a constraining facet with a single value, such as maxLength.
+type xsdFacet struct {
+	Value string `xml:"value,attr"`
+}
+
 type xsdEnumeration struct {
 	Value string `xml:"value,attr"`
 }